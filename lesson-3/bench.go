@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"testing"
+)
+
+var (
+	genBrowsers = []string{
+		"Mozilla/5.0 (Linux; U; Android 4.0.3; ko-kr; LG-L160L Build/IML74K) AppleWebkit/534.30 (KHTML, like Gecko) Version/4.0 Mobile Safari/534.30",
+		"Mozilla/5.0 (Linux; Android 5.1.1; Nexus 5 Build/LMY48B) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/43.0.2357.65 Mobile Safari/537.36",
+		"Mozilla/5.0 (Android 2.2; Windows; U; Windows NT 6.1; en-US) AppleWebKit/533.19.4 (KHTML, like Gecko) Version/5.0.3 Safari/533.19.4",
+		"Mozilla/5.0 (compatible; MSIE 10.0; Windows NT 6.1; Trident/6.0)",
+		"Mozilla/4.0 (compatible; MSIE 8.0; Windows NT 5.1; Trident/4.0)",
+		"Mozilla/5.0 (compatible; MSIE 9.0; Windows NT 6.0; Trident/5.0)",
+		"Mozilla/5.0 (Windows NT 6.1; WOW64; rv:40.0) Gecko/20100101 Firefox/40.1",
+		"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/41.0.2227.0 Safari/537.36",
+		"Opera/9.80 (X11; Linux i686; Ubuntu/14.10) Presto/2.12.388 Version/12.16",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_9_3) AppleWebKit/537.75.14 (KHTML, like Gecko) Version/7.0.3 Safari/7046A194A",
+	}
+	genFirstNames = []string{"Sharon", "Jonathan", "Maria", "Dmitry", "Anna", "Peter", "Olga", "Kevin"}
+	genLastNames  = []string{"Crawford", "Morris", "Ivanov", "Smith", "Petrova", "Lee", "Garcia"}
+	genDomains    = []string{"Muxo.edu", "mail.ru", "Flashpoint.com", "gmail.com", "Skinix.net"}
+)
+
+// genUser - generated user, has the same fields as lines of data/users.txt
+type genUser struct {
+	Browsers []string `json:"browsers"`
+	Company  string   `json:"company"`
+	Country  string   `json:"country"`
+	Email    string   `json:"email"`
+	Job      string   `json:"job"`
+	Name     string   `json:"name"`
+	Phone    string   `json:"phone"`
+}
+
+// generateUsers - write n random users as json lines, the same seed gives the same file
+func generateUsers(out io.Writer, n int, seed int64) error {
+	rnd := rand.New(rand.NewSource(seed))
+	w := bufio.NewWriter(out)
+	for i := 0; i < n; i++ {
+		first := genFirstNames[rnd.Intn(len(genFirstNames))]
+		last := genLastNames[rnd.Intn(len(genLastNames))]
+		user := genUser{
+			Company: fmt.Sprintf("Company%d", rnd.Intn(100)),
+			Country: fmt.Sprintf("Country%d", rnd.Intn(50)),
+			Email:   first + last + "@" + genDomains[rnd.Intn(len(genDomains))],
+			Job:     "Programmer Analyst #{N}",
+			Name:    first + " " + last,
+			Phone:   fmt.Sprintf("%03d-%02d-%02d", rnd.Intn(1000), rnd.Intn(100), rnd.Intn(100)),
+		}
+		for j := 1 + rnd.Intn(4); j > 0; j-- {
+			user.Browsers = append(user.Browsers, genBrowsers[rnd.Intn(len(genBrowsers))])
+		}
+		line, err := json.Marshal(user)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			w.WriteByte('\n')
+		}
+		w.Write(line)
+	}
+	return w.Flush()
+}
+
+// BenchResult - one benchmark of one search
+type BenchResult struct {
+	NsPerOp     int64 `json:"ns_per_op"`
+	AllocsPerOp int64 `json:"allocs_per_op"`
+	BytesPerOp  int64 `json:"bytes_per_op"`
+}
+
+// Baseline - stored results, key is "search/users", e.g. "fast/1000"
+type Baseline map[string]BenchResult
+
+func benchKey(search string, users int) string {
+	return fmt.Sprintf("%s/%d", search, users)
+}
+
+// BenchConfig - settings of benchmark harness run
+type BenchConfig struct {
+	Users    int
+	Seed     int64
+	Searches []string
+	// ProfileDir - where cpu_<search>.out, mem_<search>_base.out and mem_<search>.out go,
+	// profiles are not written if empty
+	ProfileDir string
+}
+
+// runBench - generate dataset and benchmark every search on it
+func runBench(cfg BenchConfig) (Baseline, error) {
+	data, err := ioutil.TempFile("", "users")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(data.Name())
+	err = generateUsers(data, cfg.Users, cfg.Seed)
+	if cerr := data.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	opts := SearchOptions{Mode: ParseStrict, Path: data.Name()}
+	results := Baseline{}
+	for _, name := range cfg.Searches {
+		search, ok := searches[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown search %q", name)
+		}
+		// check search works before measuring it
+		if _, err := search(ioutil.Discard, opts); err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+
+		var stopProfile func() error
+		if cfg.ProfileDir != "" {
+			stopProfile, err = startProfile(cfg.ProfileDir, name)
+			if err != nil {
+				return nil, err
+			}
+		}
+		res := testing.Benchmark(func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				search(ioutil.Discard, opts)
+			}
+		})
+		if stopProfile != nil {
+			if err := stopProfile(); err != nil {
+				return nil, err
+			}
+		}
+
+		results[benchKey(name, cfg.Users)] = BenchResult{
+			NsPerOp:     res.NsPerOp(),
+			AllocsPerOp: res.AllocsPerOp(),
+			BytesPerOp:  res.AllocedBytesPerOp(),
+		}
+	}
+	return results, nil
+}
+
+// startProfile - write heap profile taken before the search and start cpu profile,
+// returned func stops it and writes heap profile taken after the search.
+// Heap profile counts allocations since the program start, allocations of the search alone are shown by
+// go tool pprof -sample_index=alloc_space -base mem_<search>_base.out mem_<search>.out
+func startProfile(dir, name string) (func() error, error) {
+	if err := writeHeapProfile(filepath.Join(dir, "mem_"+name+"_base.out")); err != nil {
+		return nil, err
+	}
+	cpu, err := os.Create(filepath.Join(dir, "cpu_"+name+".out"))
+	if err != nil {
+		return nil, err
+	}
+	if err := pprof.StartCPUProfile(cpu); err != nil {
+		cpu.Close()
+		return nil, err
+	}
+	return func() error {
+		pprof.StopCPUProfile()
+		if err := cpu.Close(); err != nil {
+			return err
+		}
+		return writeHeapProfile(filepath.Join(dir, "mem_"+name+".out"))
+	}, nil
+}
+
+// writeHeapProfile - heap profile is updated by GC, so GC runs right before it is written
+func writeHeapProfile(path string) error {
+	mem, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	runtime.GC()
+	err = pprof.WriteHeapProfile(mem)
+	if cerr := mem.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Regression - metric which got worse than baseline allows
+type Regression struct {
+	Key      string
+	Metric   string
+	Baseline int64
+	Current  int64
+}
+
+func (r Regression) String() string {
+	return fmt.Sprintf("%s %s: %d -> %d (%+.1f%%)", r.Key, r.Metric, r.Baseline, r.Current,
+		100*float64(r.Current-r.Baseline)/float64(r.Baseline))
+}
+
+// compareBaseline - ns/op and allocs/op which grew more than threshold, 0.1 means 10%.
+// Results missing in baseline can not be compared, their keys are returned as missing
+func compareBaseline(baseline, current Baseline, threshold float64) (regressions []Regression, missing []string) {
+	keys := make([]string, 0, len(current))
+	for key := range current {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		old, ok := baseline[key]
+		if !ok {
+			missing = append(missing, key)
+			continue
+		}
+		cur := current[key]
+		if exceeds(old.NsPerOp, cur.NsPerOp, threshold) {
+			regressions = append(regressions, Regression{key, "ns/op", old.NsPerOp, cur.NsPerOp})
+		}
+		if exceeds(old.AllocsPerOp, cur.AllocsPerOp, threshold) {
+			regressions = append(regressions, Regression{key, "allocs/op", old.AllocsPerOp, cur.AllocsPerOp})
+		}
+	}
+	return regressions, missing
+}
+
+func exceeds(old, cur int64, threshold float64) bool {
+	if old == 0 {
+		return cur > 0
+	}
+	return float64(cur) > float64(old)*(1+threshold)
+}
+
+// loadBaseline - read baseline json, missing file gives empty baseline
+func loadBaseline(path string) (Baseline, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Baseline{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	baseline := Baseline{}
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("cant unpack baseline %s: %s", path, err)
+	}
+	return baseline, nil
+}
+
+// saveBaseline - merge results into baseline file
+func saveBaseline(path string, results Baseline) error {
+	baseline, err := loadBaseline(path)
+	if err != nil {
+		return err
+	}
+	for key, res := range results {
+		baseline[key] = res
+	}
+	data, err := json.MarshalIndent(baseline, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
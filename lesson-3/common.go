@@ -2,46 +2,64 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"regexp"
-	"strings"
 	// "log"
 )
 
 const filePath string = "/Users/dgkrivenko/go/src/github.com/dgkrivenko/cursera-go-pt1/lesson-3/data/users.txt"
 
 func SlowSearch(out io.Writer) {
-	file, err := os.Open(filePath)
-	if err != nil {
+	if _, err := SlowSearchWith(out, SearchOptions{}); err != nil {
 		panic(err)
 	}
+}
 
-	fileContents, err := ioutil.ReadAll(file)
+// SlowSearchWith - SlowSearch which handles bad lines according to opts.Mode
+func SlowSearchWith(out io.Writer, opts SearchOptions) ([]*LineError, error) {
+	red, err := opts.Redaction.newRedactor()
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	file, err := os.Open(opts.path())
+	if err != nil {
+		return nil, err
 	}
+	defer file.Close()
 
-	r := regexp.MustCompile("@")
 	seenBrowsers := []string{}
 	uniqueBrowsers := 0
-	foundUsers := ""
 
-	lines := strings.Split(string(fileContents), "\n")
+	// lines are split the same way as in FastSearch and ScanSearch, so the same lines are rejected
+	scanner := newLineScanner(file)
 
 	users := make([]map[string]interface{}, 0)
-	for _, line := range lines {
+	var rejected []*LineError
+	for n := 1; scanner.Scan(); n++ {
 		user := make(map[string]interface{})
 		// fmt.Printf("%v %v\n", err, line)
-		err := json.Unmarshal([]byte(line), &user)
+		err := scanner.LineErr()
+		if err == nil {
+			err = json.Unmarshal(scanner.Bytes(), &user)
+		}
 		if err != nil {
-			panic(err)
+			if err := opts.reject(&rejected, n, err); err != nil {
+				return rejected, err
+			}
+			// keep nil user so indexes of the next users stay the same
+			user = nil
 		}
 		users = append(users, user)
 	}
+	if err := scanner.Err(); err != nil {
+		return rejected, err
+	}
 
+	format := opts.formatter(out)
+	if err := format.Header(); err != nil {
+		return rejected, err
+	}
 	for i, user := range users {
 
 		isAndroid := false
@@ -102,10 +120,13 @@ func SlowSearch(out io.Writer) {
 		}
 
 		// log.Println("Android and MSIE user:", user["name"], user["email"])
-		email := r.ReplaceAllString(user["email"].(string), " [at] ")
-		foundUsers += fmt.Sprintf("[%d] %s <%s>\n", i, user["name"], email)
+		// null or missing email and name are printed empty, like FastSearch and ScanSearch do
+		email, _ := user["email"].(string)
+		name, _ := user["name"].(string)
+		if err := format.User(i, red.name(nil, []byte(name)), red.email(nil, []byte(email))); err != nil {
+			return rejected, err
+		}
 	}
 
-	fmt.Fprintln(out, "found users:\n"+foundUsers)
-	fmt.Fprintln(out, "Total unique browsers", len(seenBrowsers))
+	return rejected, format.Footer(SearchSummary{len(seenBrowsers), rejected})
 }
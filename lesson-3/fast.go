@@ -3,13 +3,12 @@ package main
 import (
 	"bufio"
 	json "encoding/json"
-	"fmt"
+	"errors"
 	easyjson "github.com/mailru/easyjson"
 	jlexer "github.com/mailru/easyjson/jlexer"
 	jwriter "github.com/mailru/easyjson/jwriter"
 	"io"
 	"os"
-	"regexp"
 	"strings"
 )
 type User struct {
@@ -140,25 +139,63 @@ func (v *User) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjson9e1087fdDecodeGithubComDgkrivenkoCurseraGoPt1Lesson3Easy(l, v)
 }
 
+// errInvalidJSON - line is rejected by json.Valid
+var errInvalidJSON = errors.New("invalid json")
+
 func FastSearch(out io.Writer) {
-	file, err := os.Open(filePath)
-	if err != nil {
+	if _, err := FastSearchWith(out, SearchOptions{}); err != nil {
 		panic(err)
 	}
+}
 
-	r := regexp.MustCompile("@")
-	var seenBrowsers []string
-	foundUsers := ""
+// FastSearchWith - FastSearch which handles bad lines according to opts.Mode
+func FastSearchWith(out io.Writer, opts SearchOptions) ([]*LineError, error) {
+	red, err := opts.Redaction.newRedactor()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(opts.path())
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var name, email []byte
+	var seen seenBrowsers
+	var rejected []*LineError
 
-	scanner := bufio.NewScanner(file)
+	w := bufio.NewWriter(out)
+	format := opts.formatter(w)
+	if err := format.Header(); err != nil {
+		return nil, err
+	}
+
+	scanner := newLineScanner(file)
 	i := -1
 	for scanner.Scan() {
 		i++
+		if err := scanner.LineErr(); err != nil {
+			if err := opts.reject(&rejected, i+1, err); err != nil {
+				return rejected, err
+			}
+			continue
+		}
 		line := scanner.Bytes()
 		user := User{}
-		err := user.UnmarshalJSON(line)
+		var err error
+		// easyjson does not check values it skips and contents of strings, so in strict mode
+		// the line is validated first to stop on the same line as SlowSearch.
+		// Lenient mode skips only lines easyjson can not read, validation would double the parsing
+		if opts.Mode == ParseStrict && !json.Valid(line) {
+			err = errInvalidJSON
+		} else {
+			err = user.UnmarshalJSON(line)
+		}
 		if err != nil {
-			panic(err)
+			if err := opts.reject(&rejected, i+1, err); err != nil {
+				return rejected, err
+			}
+			continue
 		}
 
 		var isAndroid bool
@@ -168,17 +205,7 @@ func FastSearch(out io.Writer) {
 			isAndroidLocal := strings.Contains(browser, "Android")
 			isMSIELocal := strings.Contains(browser, "MSIE")
 			if isAndroidLocal || isMSIELocal {
-				notSeenBefore := true
-			UniqLoop:
-				for _, item := range seenBrowsers {
-					if item == browser {
-						notSeenBefore = false
-						break UniqLoop
-					}
-				}
-				if notSeenBefore {
-					seenBrowsers = append(seenBrowsers, browser)
-				}
+				seen.add(browser)
 			}
 			if isAndroidLocal {
 				isAndroid = true
@@ -188,11 +215,83 @@ func FastSearch(out io.Writer) {
 			}
 		}
 		if isAndroid && isMSIE {
-			email := r.ReplaceAllString(user.Email, " [at] ")
-			foundUsers += fmt.Sprintf("[%d] %s <%s>\n", i, user.Name, email)
+			name = red.name(name[:0], []byte(user.Name))
+			email = red.email(email[:0], []byte(user.Email))
+			if err := format.User(i, name, email); err != nil {
+				return rejected, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return rejected, err
+	}
+	if err := format.Footer(SearchSummary{seen.len(), rejected}); err != nil {
+		return rejected, err
+	}
+	return rejected, w.Flush()
+}
+
+// seenBrowsers - browsers in order of first appearance.
+// Searches keep only Android and MSIE browsers, such sets are small,
+// so linear scan works faster than map here. Report sees every browser of the file,
+// for it the map index is built once the set outgrows seenLinearMax
+type seenBrowsers struct {
+	list  []string
+	index map[string]struct{}
+}
+
+// seenLinearMax - size of set which is still scanned linearly
+const seenLinearMax = 32
+
+// add - remember browser, returns true if it was not seen before
+func (s *seenBrowsers) add(browser string) bool {
+	if s.index != nil {
+		if _, ok := s.index[browser]; ok {
+			return false
+		}
+	} else {
+		for _, item := range s.list {
+			if item == browser {
+				return false
+			}
+		}
+	}
+	s.remember(browser)
+	return true
+}
+
+// addBytes - same as add, but copies browser only when it is new
+func (s *seenBrowsers) addBytes(browser []byte) bool {
+	if s.index != nil {
+		if _, ok := s.index[string(browser)]; ok {
+			return false
+		}
+	} else {
+		for _, item := range s.list {
+			if item == string(browser) {
+				return false
+			}
 		}
 	}
-	fmt.Fprintln(out, "found users:\n"+foundUsers)
-	fmt.Fprintln(out, "Total unique browsers", len(seenBrowsers))
+	s.remember(string(browser))
+	return true
+}
+
+func (s *seenBrowsers) remember(browser string) {
+	s.list = append(s.list, browser)
+	switch {
+	case s.index != nil:
+		s.index[browser] = struct{}{}
+	case len(s.list) > seenLinearMax:
+		s.index = make(map[string]struct{}, 2*len(s.list))
+		for _, item := range s.list {
+			s.index[item] = struct{}{}
+		}
+	}
+}
+
+// len - number of distinct browsers
+func (s *seenBrowsers) len() int {
+	return len(s.list)
 }
 
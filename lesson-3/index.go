@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ErrStaleIndex - users file was changed after the index was built
+var ErrStaleIndex = errors.New("index is stale")
+
+// Index - inverted index over users file.
+// User id is the line index, the same one SlowSearch and FastSearch print
+type Index struct {
+	Source  string
+	Size    int64
+	ModTime int64 // unix nano
+	// Offsets - start of every line in source, the last item is the file size
+	Offsets  []int64
+	Browsers map[string][]int // browser token -> user ids
+	Emails   map[string][]int // lowercased email -> user ids
+	Domains  map[string][]int // lowercased email domain -> user ids
+	// UniqueBrowsers - Android and MSIE browsers of the file, counted the same way as searches do
+	UniqueBrowsers int
+	// Rejected - lines skipped in lenient mode
+	Rejected []indexRejected
+}
+
+// indexRejected - skipped line, gob can not encode error values, so only the message is kept
+type indexRejected struct {
+	Line  int
+	Error string
+}
+
+// sentinelErrors - line errors restored from index as they are, so errors.Is works for them
+var sentinelErrors = []error{bufio.ErrTooLong, errInvalidJSON}
+
+// rejected - skipped lines as LineError, the same as searches return
+func (idx *Index) rejected() []*LineError {
+	var rejected []*LineError
+	for _, r := range idx.Rejected {
+		err := errors.New(r.Error)
+		for _, sentinel := range sentinelErrors {
+			if sentinel.Error() == r.Error {
+				err = sentinel
+			}
+		}
+		rejected = append(rejected, &LineError{Line: r.Line, Err: err})
+	}
+	return rejected
+}
+
+// IndexQuery - users must match all browser tokens and email, empty fields are not checked.
+// Email starting with @ matches the whole domain
+type IndexQuery struct {
+	Browsers []string
+	Email    string
+}
+
+// indexPath - default location of index for users file
+func indexPath(source string) string {
+	return source + ".idx"
+}
+
+// browserTokens - split user agent into words and versions, e.g. "MSIE", "9.0"
+func browserTokens(browser []byte) [][]byte {
+	return bytes.FieldsFunc(browser, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.' && r != '_' && r != '-'
+	})
+}
+
+// addPosting - append user id once, ids come in ascending order
+func addPosting(postings map[string][]int, key string, id int) {
+	ids := postings[key]
+	if len(ids) > 0 && ids[len(ids)-1] == id {
+		return
+	}
+	postings[key] = append(ids, id)
+}
+
+// BuildIndex - read users file, build index and save it next to the file.
+// Lines are read and rejected the same way as in searches, according to opts.Mode
+func BuildIndex(opts SearchOptions) (*Index, error) {
+	source := opts.path()
+	file, err := os.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	idx := &Index{
+		Source:   source,
+		Size:     stat.Size(),
+		ModTime:  stat.ModTime().UnixNano(),
+		Browsers: map[string][]int{},
+		Emails:   map[string][]int{},
+		Domains:  map[string][]int{},
+	}
+
+	var rejected []*LineError
+	var seen seenBrowsers
+	user := lineUser{}
+	scanner := newLineScanner(file)
+	var offset int64
+	for id := 0; scanner.Scan(); id++ {
+		idx.Offsets = append(idx.Offsets, offset)
+		offset = scanner.Offset()
+
+		err := scanner.LineErr()
+		if err == nil {
+			err = user.parse(scanner.Bytes())
+		}
+		if err != nil {
+			if err := opts.reject(&rejected, id+1, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for _, browser := range user.Browsers {
+			if bytes.Contains(browser, []byte("Android")) || bytes.Contains(browser, []byte("MSIE")) {
+				seen.addBytes(browser)
+			}
+			for _, token := range browserTokens(browser) {
+				addPosting(idx.Browsers, string(token), id)
+			}
+		}
+		if len(user.Email) > 0 {
+			email := strings.ToLower(string(user.Email))
+			addPosting(idx.Emails, email, id)
+			if at := strings.LastIndexByte(email, '@'); at >= 0 {
+				addPosting(idx.Domains, email[at+1:], id)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	idx.Offsets = append(idx.Offsets, offset)
+	idx.UniqueBrowsers = seen.len()
+	for _, e := range rejected {
+		idx.Rejected = append(idx.Rejected, indexRejected{e.Line, e.Err.Error()})
+	}
+
+	return idx, idx.save(indexPath(source))
+}
+
+// save - write index through temporary file, so readers never see half written index
+func (idx *Index) save(path string) error {
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	err = gob.NewEncoder(w).Encode(idx)
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadIndex - read saved index of users file, returns ErrStaleIndex if file size or mtime changed.
+// Index built in lenient mode is not used in strict mode if lines were skipped, the first of them is returned
+func LoadIndex(opts SearchOptions) (*Index, error) {
+	source := opts.path()
+	file, err := os.Open(indexPath(source))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	idx := &Index{}
+	if err := gob.NewDecoder(bufio.NewReader(file)).Decode(idx); err != nil {
+		return nil, fmt.Errorf("cant decode index: %s", err)
+	}
+	stat, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+	if stat.Size() != idx.Size || stat.ModTime().UnixNano() != idx.ModTime {
+		return nil, ErrStaleIndex
+	}
+	if rejected := idx.rejected(); opts.Mode == ParseStrict && len(rejected) > 0 {
+		return nil, rejected[0]
+	}
+	return idx, nil
+}
+
+// OpenIndex - load index, build it again if it is missing, broken or stale
+func OpenIndex(opts SearchOptions) (*Index, error) {
+	idx, err := LoadIndex(opts)
+	if err == nil {
+		return idx, nil
+	}
+	return BuildIndex(opts)
+}
+
+// Find - ids of users matching query in ascending order
+func (idx *Index) Find(q IndexQuery) []int {
+	var lists [][]int
+	for _, token := range q.Browsers {
+		lists = append(lists, idx.Browsers[token])
+	}
+	if q.Email != "" {
+		email := strings.ToLower(q.Email)
+		if strings.HasPrefix(email, "@") {
+			lists = append(lists, idx.Domains[email[1:]])
+		} else {
+			lists = append(lists, idx.Emails[email])
+		}
+	}
+	if len(lists) == 0 {
+		return nil
+	}
+
+	// intersect starting from the shortest list
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+	result := append([]int(nil), lists[0]...)
+	for _, ids := range lists[1:] {
+		result = intersect(result, ids)
+	}
+	return result
+}
+
+// intersect - common items of two sorted lists, result reuses a
+func intersect(a, b []int) []int {
+	result := a[:0]
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	return result
+}
+
+// readUsers - read lines of found users straight by their offsets in source file
+func (idx *Index) readUsers(ids []int, fn func(id int, user *lineUser) error) error {
+	file, err := os.Open(idx.Source)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	user := lineUser{}
+	var buf []byte
+	for _, id := range ids {
+		if id < 0 || id+1 >= len(idx.Offsets) {
+			return fmt.Errorf("user id %d out of index", id)
+		}
+		start, end := idx.Offsets[id], idx.Offsets[id+1]
+		if cap(buf) < int(end-start) {
+			buf = make([]byte, end-start)
+		}
+		buf = buf[:end-start]
+		if _, err := file.ReadAt(buf, start); err != nil {
+			return err
+		}
+		if err := user.parse(bytes.TrimRight(buf, "\r\n")); err != nil {
+			return &LineError{Line: id + 1, Err: err}
+		}
+		if err := fn(id, &user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IndexSearch - print users matching query through opts.Formatter like other searches, using index of users file.
+// Footer has unique browsers and rejected lines of the whole file, they are kept in index
+func IndexSearch(out io.Writer, opts SearchOptions, q IndexQuery) ([]*LineError, error) {
+	red, err := opts.Redaction.newRedactor()
+	if err != nil {
+		return nil, err
+	}
+	idx, err := OpenIndex(opts)
+	if err != nil {
+		return nil, err
+	}
+	rejected := idx.rejected()
+	w := bufio.NewWriter(out)
+	format := opts.formatter(w)
+	if err := format.Header(); err != nil {
+		return rejected, err
+	}
+	name := make([]byte, 0, 64)
+	email := make([]byte, 0, 64)
+	err = idx.readUsers(idx.Find(q), func(id int, user *lineUser) error {
+		name = red.name(name[:0], user.Name)
+		email = red.email(email[:0], user.Email)
+		return format.User(id, name, email)
+	})
+	if err != nil {
+		return rejected, err
+	}
+	if err := format.Footer(SearchSummary{idx.UniqueBrowsers, rejected}); err != nil {
+		return rejected, err
+	}
+	return rejected, w.Flush()
+}
@@ -0,0 +1,109 @@
+package main
+
+// запуск бенчмарков на сгенерированных данных с профилированием и сравнением с baseline,
+// в data/baseline.json лежат результаты для 1000 пользователей:
+// go run . -users 1000 -profiles . -baseline data/baseline.json
+// аллокации одного поиска по профилю:
+// go tool pprof -sample_index=alloc_space -base mem_fast_base.out mem_fast.out
+// для другого числа пользователей или после осознанных изменений сначала обновить baseline:
+// go run . -users 10000 -baseline data/baseline.json -update
+// вместо бенчмарков можно напечатать отчет по файлу пользователей:
+// go run . -report table -input data/users.txt
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+func main() {
+	users := flag.Int("users", 1000, "number of generated users")
+	seed := flag.Int64("seed", 1, "seed of generated dataset")
+	searchNames := flag.String("searches", "slow,fast,scan", "comma separated searches to run")
+	profiles := flag.String("profiles", "", "directory for cpu_<search>.out, mem_<search>_base.out and mem_<search>.out profiles")
+	baselinePath := flag.String("baseline", "data/baseline.json", "baseline json file")
+	threshold := flag.Float64("threshold", 0.2, "allowed growth of ns/op and allocs/op, 0.2 means 20%")
+	update := flag.Bool("update", false, "write results into baseline instead of comparing")
+	report := flag.String("report", "", "print report of users file in table, csv or json format instead of running benchmarks")
+	input := flag.String("input", "", "users file for report, "+filePath+" by default")
+	top := flag.Int("top", 10, "number of most popular domains in report, 0 keeps all")
+	flag.Parse()
+
+	if *report != "" {
+		if err := ReportSearch(os.Stdout, SearchOptions{Path: *input}, *report, *top); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
+	}
+
+	results, err := runBench(BenchConfig{
+		Users:      *users,
+		Seed:       *seed,
+		Searches:   strings.Split(*searchNames, ","),
+		ProfileDir: *profiles,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	baseline, err := loadBaseline(*baselinePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	printResults(baseline, results)
+
+	if *update {
+		if err := saveBaseline(*baselinePath, results); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		fmt.Println("baseline updated:", *baselinePath)
+		return
+	}
+
+	regressions, missing := compareBaseline(baseline, results, *threshold)
+	if len(missing) > 0 {
+		fmt.Println("\nmissing in baseline, run with -update to add them:")
+		for _, key := range missing {
+			fmt.Println(key)
+		}
+	}
+	if len(regressions) > 0 {
+		fmt.Println("\nregressions:")
+		for _, r := range regressions {
+			fmt.Println(r)
+		}
+	}
+	if len(missing) > 0 || len(regressions) > 0 {
+		os.Exit(1)
+	}
+}
+
+// printResults - table of current results next to baseline ones
+func printResults(baseline, results Baseline) {
+	keys := make([]string, 0, len(results))
+	for key := range results {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SEARCH\tNS/OP\tALLOCS/OP\tB/OP\tBASE NS/OP\tBASE ALLOCS/OP")
+	for _, key := range keys {
+		cur := results[key]
+		base, ok := baseline[key]
+		if !ok {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t-\t-\n", key, cur.NsPerOp, cur.AllocsPerOp, cur.BytesPerOp)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\n", key, cur.NsPerOp, cur.AllocsPerOp, cur.BytesPerOp,
+			base.NsPerOp, base.AllocsPerOp)
+	}
+	w.Flush()
+}
@@ -1,8 +1,15 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -11,6 +18,7 @@ import (
 func init() {
 	SlowSearch(ioutil.Discard)
 	FastSearch(ioutil.Discard)
+	ScanSearch(ioutil.Discard)
 }
 
 // -----
@@ -30,6 +38,515 @@ func TestSearch(t *testing.T) {
 	}
 }
 
+func TestScanSearch(t *testing.T) {
+	slowOut := new(bytes.Buffer)
+	SlowSearch(slowOut)
+	slowResult := slowOut.String()
+
+	scanOut := new(bytes.Buffer)
+	ScanSearch(scanOut)
+	scanResult := scanOut.String()
+
+	if slowResult != scanResult {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", scanResult, slowResult)
+	}
+}
+
+func TestLineUserParse(t *testing.T) {
+	line := []byte(`{"company":{"n":[1,"]"]},"browsers":["a\u00e9\"b",null,"c"],"age":-1.5e3,"email":"x@y.z","ok":true,"name":"\ud83d\ude00 Bob"}`)
+	user := lineUser{}
+	if err := user.parse(line); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(user.Browsers) != 2 || string(user.Browsers[0]) != "a\u00e9\"b" || string(user.Browsers[1]) != "c" {
+		t.Errorf("bad browsers: %q", user.Browsers)
+	}
+	if string(user.Email) != "x@y.z" {
+		t.Errorf("bad email: %q", user.Email)
+	}
+	if string(user.Name) != "\U0001F600 Bob" {
+		t.Errorf("bad name: %q", user.Name)
+	}
+
+	for _, bad := range []string{``, `{`, `{"name":}`, `{"name":"a"`, `{"name":"a"} x`, `[]`} {
+		if err := user.parse([]byte(bad)); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		user.parse(line)
+	})
+	if allocs != 0 {
+		t.Errorf("parse allocates: %v", allocs)
+	}
+}
+
+func TestBuildReport(t *testing.T) {
+	data := `{"browsers":["Mozilla/4.0 (compatible; MSIE 8.0; Windows NT 6.1)","Mozilla/5.0 (Linux; Android 4.4.2; Nexus 5) Chrome/41.0.2227.0","Mozilla/4.0 (compatible; MSIE 8.0; Windows NT 5.1)"],"email":"a@Mail.ru","name":"A"}
+{"browsers":["Mozilla/4.0 (compatible; MSIE 8.0; Windows NT 6.1)"],"email":"b@mail.ru","name":"B"}
+{"browsers":[],"email":"c@gmail.com","name":"C"}`
+
+	report, err := BuildReport(strings.NewReader(data), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.TotalUsers != 3 || report.UniqueUserAgents != 3 {
+		t.Errorf("bad totals: %+v", report)
+	}
+	if len(report.Browsers) != 2 {
+		t.Fatalf("bad browsers: %+v", report.Browsers)
+	}
+	if b := report.Browsers[1]; b.Family != "MSIE" || b.Version != "8" || b.Users != 2 || b.Unique != 2 {
+		t.Errorf("bad MSIE stat: %+v", b)
+	}
+	expectedDomains := []DomainStat{{"mail.ru", 2}}
+	if !reflect.DeepEqual(report.Domains, expectedDomains) {
+		t.Errorf("bad domains: %+v", report.Domains)
+	}
+	expectedCombos := []ComboStat{{"Android+MSIE", 1}, {"MSIE", 1}, {"none", 1}}
+	if !reflect.DeepEqual(report.Combinations, expectedCombos) {
+		t.Errorf("bad combinations: %+v", report.Combinations)
+	}
+
+	for _, format := range []string{ReportFormatTable, ReportFormatCSV, ReportFormatJSON} {
+		out := new(bytes.Buffer)
+		if err := report.Write(out, format); err != nil || !strings.Contains(out.String(), "mail.ru") {
+			t.Errorf("bad %s output: %v\n%s", format, err, out)
+		}
+	}
+	if err := report.Write(ioutil.Discard, "xml"); err == nil {
+		t.Errorf("expected error for unknown format")
+	}
+
+	path := writeUsersFile(t, data)
+	defer os.Remove(path)
+	out := new(bytes.Buffer)
+	if err := ReportSearch(out, SearchOptions{Path: path}, ReportFormatCSV, 10); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "domain,gmail.com,,1,") {
+		t.Errorf("expected all domains of file for top 10, got\n%s", out)
+	}
+}
+
+// writeUsersFile - create temporary users file, caller removes it
+func writeUsersFile(t *testing.T, data string) string {
+	f, err := ioutil.TempFile("", "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func TestSearchParseMode(t *testing.T) {
+	path := writeUsersFile(t, `{"browsers":["MSIE 8.0","Android 4.4"],"email":"a@b.c","name":"A"}
+{"browsers":["MSIE 8.0",
+{"browsers":["MSIE 9.0","Android 5.0"],"email":"d@e.f","name":"D"}
+not json`)
+	defer os.Remove(path)
+
+	expected := "found users:\n[0] A <a [at] b.c>\n[2] D <d [at] e.f>\n\nTotal unique browsers 4\n"
+	for name, search := range searches {
+		out := new(bytes.Buffer)
+		rejected, err := search(out, SearchOptions{Mode: ParseLenient, Path: path})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		if len(rejected) != 2 || rejected[0].Line != 2 || rejected[1].Line != 4 {
+			t.Errorf("%s: bad rejected lines: %v", name, rejected)
+		}
+		if !strings.HasPrefix(out.String(), expected+"Rejected lines 2\nline 2: ") {
+			t.Errorf("%s: bad output:\n%s", name, out)
+		}
+
+		_, err = search(ioutil.Discard, SearchOptions{Mode: ParseStrict, Path: path})
+		lineErr := &LineError{}
+		if !errors.As(err, &lineErr) || lineErr.Line != 2 {
+			t.Errorf("%s: expected LineError for line 2, got %v", name, err)
+		}
+	}
+}
+
+func TestSearchLineEnds(t *testing.T) {
+	long := `{"about":"` + strings.Repeat("x", 100*1024) + `"}`
+	path := writeUsersFile(t, "{\"browsers\":[\"MSIE 8.0\",\"Android 4.4\"],\"email\":\"a@b.c\",\"name\":\"A\"}\r\n"+
+		long+"\n"+
+		`{"browsers":["MSIE 9.0","Android 5.0"],"email":"d@e.f","name":"D"}`+"\n")
+	defer os.Remove(path)
+
+	// index search reads the same file through index, built on the first query
+	lineEndSearches := map[string]SearchFunc{
+		"index": func(out io.Writer, opts SearchOptions) ([]*LineError, error) {
+			return IndexSearch(out, opts, IndexQuery{Browsers: []string{"MSIE", "Android"}})
+		},
+	}
+	for name, search := range searches {
+		lineEndSearches[name] = search
+	}
+	expected := "found users:\n[0] A <a [at] b.c>\n[2] D <d [at] e.f>\n\nTotal unique browsers 4\n" +
+		"Rejected lines 1\nline 2: " + bufio.ErrTooLong.Error() + "\n"
+	defer os.Remove(indexPath(path))
+	for name, search := range lineEndSearches {
+		out := new(bytes.Buffer)
+		rejected, err := search(out, SearchOptions{Mode: ParseLenient, Path: path})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		if len(rejected) != 1 || !errors.Is(rejected[0], bufio.ErrTooLong) {
+			t.Errorf("%s: bad rejected lines: %v", name, rejected)
+		}
+		if out.String() != expected {
+			t.Errorf("%s: bad output:\n%s", name, out)
+		}
+
+		_, err = search(ioutil.Discard, SearchOptions{Mode: ParseStrict, Path: path})
+		lineErr := &LineError{}
+		if !errors.As(err, &lineErr) || lineErr.Line != 2 {
+			t.Errorf("%s: expected LineError for line 2, got %v", name, err)
+		}
+	}
+}
+
+func TestSearchNullFields(t *testing.T) {
+	path := writeUsersFile(t, `{"browsers":["MSIE 8.0","Android 4.4"],"email":null,"name":"A"}
+{"browsers":["MSIE 9.0","Android 5.0"],"name":null}
+{"browsers":["MSIE 9.0","Android 5.0"],"email":"d@e.f","name":"D"}`)
+	defer os.Remove(path)
+
+	expected := "found users:\n[0] A <>\n[1]  <>\n[2] D <d [at] e.f>\n\nTotal unique browsers 4\n"
+	for name, search := range searches {
+		for _, mode := range []ParseMode{ParseLenient, ParseStrict} {
+			out := new(bytes.Buffer)
+			rejected, err := search(out, SearchOptions{Mode: mode, Path: path})
+			if err != nil || len(rejected) != 0 {
+				t.Errorf("%s: unexpected error: %v %v", name, err, rejected)
+				continue
+			}
+			if out.String() != expected {
+				t.Errorf("%s: bad output:\n%s", name, out)
+			}
+		}
+	}
+}
+
+func TestSearchMalformedLines(t *testing.T) {
+	user := `"browsers":["MSIE 8.0","Android 4.4"],"email":"a@b.c","name":"A"`
+	lines := []string{
+		`{` + user + `}`,
+		`{"company":{"a":[1}},` + user + `}`,
+		`{"company":{"a":{"b":1]},` + user + `}`,
+		`{"tags":[1,],` + user + `}`,
+		`{"tags":[1 2],` + user + `}`,
+		`{"age":1-2,` + user + `}`,
+		`{"age":--1,` + user + `}`,
+		`{"age":01,` + user + `}`,
+		`{"age":1.,` + user + `}`,
+		`{"age":-,` + user + `}`,
+		`{"age":1e,` + user + `}`,
+		`{"age":.5,` + user + `}`,
+		`{"ok":tru,` + user + `}`,
+		"{\"about\":\"a\tb\"," + user + `}`,
+		"{\"tags\":[\"a\tb\"]," + user + `}`,
+		`{"about":"\x",` + user + `}`,
+		`{"about":"\u12",` + user + `}`,
+		"{\"browsers\":[\"MSIE 8.0\",\"Android 4.4\"],\"email\":\"a@b.c\",\"name\":\"A\tB\"}",
+		`{` + user + `}`,
+	}
+	path := writeUsersFile(t, strings.Join(lines, "\n"))
+	defer os.Remove(path)
+
+	expected := "found users:\n[0] A <a [at] b.c>\n[18] A <a [at] b.c>\n\nTotal unique browsers 2\nRejected lines 17\n"
+	for name, search := range searches {
+		out := new(bytes.Buffer)
+		rejected, err := search(out, SearchOptions{Mode: ParseLenient, Path: path})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		var lineNumbers []int
+		for _, e := range rejected {
+			lineNumbers = append(lineNumbers, e.Line)
+		}
+		if name == "fast" {
+			// fast validates lines only in strict mode, lenient one skips just lines easyjson can not read
+			if fmt.Sprint(lineNumbers) != "[2 3 4 5 6 7 12 13 15]" || !strings.Contains(out.String(), "\nRejected lines 9\n") {
+				t.Errorf("%s: bad rejected lines: %v\n%s", name, rejected, out)
+			}
+		} else {
+			if fmt.Sprint(lineNumbers) != "[2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 18]" {
+				t.Errorf("%s: bad rejected lines: %v", name, rejected)
+			}
+			if !strings.HasPrefix(out.String(), expected) {
+				t.Errorf("%s: bad output:\n%s", name, out)
+			}
+		}
+
+		_, err = search(ioutil.Discard, SearchOptions{Mode: ParseStrict, Path: path})
+		lineErr := &LineError{}
+		if !errors.As(err, &lineErr) || lineErr.Line != 2 {
+			t.Errorf("%s: expected LineError for line 2, got %v", name, err)
+		}
+	}
+}
+
+func TestSearchFormatters(t *testing.T) {
+	path := writeUsersFile(t, `{"browsers":["MSIE 8.0","Android 4.4"],"email":"a@b.c","name":"A, \"the\" first"}
+{"browsers":["MSIE 8.0"],"email":"x@y.z","name":"X"}
+{"browsers":["MSIE 9.0","Android 5.0"],"email":"d@e.f","name":"D"}
+bad`)
+	defer os.Remove(path)
+
+	cases := []struct {
+		formatter NewFormatter
+		expected  string
+	}{
+		{NewJSONLinesFormatter, `{"id":0,"name":"A, \"the\" first","email":"a [at] b.c"}
+{"id":2,"name":"D","email":"d [at] e.f"}
+{"total_unique_browsers":4,"rejected":[{"line":4,"error":"`},
+		{NewCSVFormatter, `id,name,email
+0,"A, ""the"" first",a [at] b.c
+2,D,d [at] e.f
+# Total unique browsers 4
+# Rejected lines 1
+# line 4: `},
+	}
+	for _, c := range cases {
+		for name, search := range searches {
+			out := new(bytes.Buffer)
+			_, err := search(out, SearchOptions{Mode: ParseLenient, Path: path, Formatter: c.formatter})
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", name, err)
+			}
+			if !strings.HasPrefix(out.String(), c.expected) {
+				t.Errorf("%s: bad output\nGot:\n%v\nExpected:\n%v", name, out, c.expected)
+			}
+		}
+	}
+
+	// output fails right on the rejected lines summary
+	text := "found users:\n[0] A, \"the\" first <a [at] b.c>\n[2] D <d [at] e.f>\n\nTotal unique browsers 4\n"
+	for name, search := range searches {
+		out := &limitedWriter{limit: len(text)}
+		_, err := search(out, SearchOptions{Mode: ParseLenient, Path: path})
+		if err != errWriteLimit {
+			t.Errorf("%s: expected write error, got %v", name, err)
+		}
+	}
+}
+
+var errWriteLimit = errors.New("write limit reached")
+
+// limitedWriter - fails when more than limit bytes are written
+type limitedWriter struct {
+	limit int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		n := w.limit
+		w.limit = 0
+		return n, errWriteLimit
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+func TestSearchRedaction(t *testing.T) {
+	path := writeUsersFile(t, `{"browsers":["MSIE 8.0","Android 4.4"],"email":"john@mail.ru","name":"John Smith"}
+{"browsers":["MSIE 9.0","Android 5.0"],"email":"Ольга@mail.ru","name":"Ольга"}`)
+	defer os.Remove(path)
+	defer os.Remove(indexPath(path))
+
+	cases := []struct {
+		redaction Redaction
+		expected  string
+	}{
+		{Redaction{}, "[0] John Smith <john [at] mail.ru>\n[1] Ольга <Ольга [at] mail.ru>\n"},
+		{Redaction{Email: PolicyKeep}, "[0] John Smith <john@mail.ru>\n[1] Ольга <Ольга@mail.ru>\n"},
+		{Redaction{Email: PolicyMask, Name: PolicyMask}, "[0] J*** S*** <j***@mail.ru>\n[1] О*** <О***@mail.ru>\n"},
+		{Redaction{Email: PolicyDrop, Name: PolicyDrop}, "[0]  <>\n[1]  <>\n"},
+		{Redaction{Email: PolicyHash, Name: PolicyKeep, Salt: []byte("salt")}, "[0] John Smith <99426cb2f3bb2024>\n[1] Ольга <5c455ff61b8e649a>\n"},
+	}
+	for _, c := range cases {
+		opts := SearchOptions{Path: path, Redaction: c.redaction}
+		expected := "found users:\n" + c.expected + "\nTotal unique browsers 4\n"
+
+		slowOut := new(bytes.Buffer)
+		if _, err := SlowSearchWith(slowOut, opts); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		fastOut := new(bytes.Buffer)
+		if _, err := FastSearchWith(fastOut, opts); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		scanOut := new(bytes.Buffer)
+		if _, err := ScanSearchWith(scanOut, opts); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		indexOut := new(bytes.Buffer)
+		if _, err := IndexSearch(indexOut, opts, IndexQuery{Browsers: []string{"MSIE", "Android"}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if slowOut.String() != expected {
+			t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", slowOut, expected)
+		}
+		if fastOut.String() != expected || scanOut.String() != expected {
+			t.Errorf("fast and scan results not match slow\n%v\n%v", fastOut, scanOut)
+		}
+		if indexOut.String() != expected {
+			t.Errorf("index results not match\n%v", indexOut)
+		}
+	}
+
+	noSalt := SearchOptions{Path: path, Redaction: Redaction{Email: PolicyHash}}
+	if _, err := FastSearchWith(ioutil.Discard, noSalt); err != ErrNoSalt {
+		t.Errorf("expected ErrNoSalt, got %v", err)
+	}
+	if _, err := IndexSearch(ioutil.Discard, noSalt, IndexQuery{}); err != ErrNoSalt {
+		t.Errorf("expected ErrNoSalt, got %v", err)
+	}
+
+	if _, err := ParseFieldPolicy("hash"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := ParseFieldPolicy("encrypt"); err == nil {
+		t.Errorf("expected error for unknown policy")
+	}
+}
+
+func TestIndexSearch(t *testing.T) {
+	path := writeUsersFile(t, `{"browsers":["Mozilla/4.0 (compatible; MSIE 8.0)","Mozilla/5.0 (Linux; Android 4.4)"],"email":"a@Mail.ru","name":"A"}
+broken line
+{"browsers":["Mozilla/4.0 (compatible; MSIE 9.0)"],"email":"b@mail.ru","name":"B"}
+{"browsers":["Mozilla/5.0 (Android 5.0) MSIE"],"email":"c@gmail.com","name":"C"}
+`)
+	defer os.Remove(path)
+	defer os.Remove(indexPath(path))
+	opts := SearchOptions{Mode: ParseLenient, Path: path}
+
+	if _, err := LoadIndex(opts); err == nil {
+		t.Errorf("expected error for missing index")
+	}
+	if _, err := BuildIndex(SearchOptions{Path: path}); err == nil {
+		t.Errorf("expected error for bad line in strict mode")
+	}
+
+	out := new(bytes.Buffer)
+	rejected, err := IndexSearch(out, opts, IndexQuery{Browsers: []string{"Android", "MSIE"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "found users:\n[0] A <a [at] Mail.ru>\n[3] C <c [at] gmail.com>\n\nTotal unique browsers 4\n" +
+		"Rejected lines 1\nline 2: offset 0: expected '{', got 'b'\n"
+	if out.String() != expected || len(rejected) != 1 || rejected[0].Line != 2 {
+		t.Errorf("results not match\nGot:\n%v%v\nExpected:\n%v", out, rejected, expected)
+	}
+
+	// index built in lenient mode keeps skipped lines, strict mode gets the first of them
+	lineErr := &LineError{}
+	if _, err := LoadIndex(SearchOptions{Path: path}); !errors.As(err, &lineErr) || lineErr.Line != 2 {
+		t.Errorf("expected LineError for line 2 in strict mode, got %v", err)
+	}
+	out.Reset()
+	_, err = IndexSearch(out, SearchOptions{Mode: ParseLenient, Path: path, Formatter: NewJSONLinesFormatter}, IndexQuery{Email: "b@mail.ru"})
+	if expected := `{"id":2,"name":"B","email":"b [at] mail.ru"}` + "\n" +
+		`{"total_unique_browsers":4,"rejected":[{"line":2,"error":"offset 0: expected '{', got 'b'"}]}` + "\n"; err != nil || out.String() != expected {
+		t.Errorf("bad json output: %v\n%s", err, out)
+	}
+
+	idx, err := LoadIndex(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cases := map[string]IndexQuery{
+		"[0 2]": {Email: "@MAIL.RU"},
+		"[3]":   {Email: "c@gmail.com", Browsers: []string{"Android"}},
+		"[2]":   {Browsers: []string{"MSIE", "9.0"}},
+		"[]":    {Browsers: []string{"Opera"}},
+	}
+	for expected, q := range cases {
+		if got := fmt.Sprint(idx.Find(q)); got != expected {
+			t.Errorf("bad result for %+v: %s, expected %s", q, got, expected)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"browsers":["Android 7.0; MSIE 10.0"],"email":"d@mail.ru","name":"D"}`)
+	f.Close()
+	if _, err := LoadIndex(opts); err != ErrStaleIndex {
+		t.Errorf("expected ErrStaleIndex, got %v", err)
+	}
+	idx, err = OpenIndex(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := fmt.Sprint(idx.Find(IndexQuery{Email: "@mail.ru"})); got != "[0 2 4]" {
+		t.Errorf("index was not rebuilt: %s", got)
+	}
+}
+
+func TestGenerateUsers(t *testing.T) {
+	data := new(bytes.Buffer)
+	if err := generateUsers(data, 200, 42); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	path := writeUsersFile(t, data.String())
+	defer os.Remove(path)
+
+	opts := SearchOptions{Path: path}
+	slowOut := new(bytes.Buffer)
+	if _, err := SlowSearchWith(slowOut, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fastOut := new(bytes.Buffer)
+	if _, err := FastSearchWith(fastOut, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if slowOut.String() != fastOut.String() || !strings.Contains(slowOut.String(), "[at]") {
+		t.Errorf("bad results on generated data\n%v\n%v", slowOut, fastOut)
+	}
+
+	again := new(bytes.Buffer)
+	generateUsers(again, 200, 42)
+	if again.String() != data.String() {
+		t.Errorf("same seed gives different data")
+	}
+}
+
+func TestCompareBaseline(t *testing.T) {
+	baseline := Baseline{
+		"fast/1000": {NsPerOp: 1000, AllocsPerOp: 100},
+		"slow/1000": {NsPerOp: 1000, AllocsPerOp: 100},
+	}
+	current := Baseline{
+		"fast/1000": {NsPerOp: 1150, AllocsPerOp: 100},
+		"slow/1000": {NsPerOp: 900, AllocsPerOp: 130},
+		"scan/1000": {NsPerOp: 5000, AllocsPerOp: 5000},
+	}
+	regressions, missing := compareBaseline(baseline, current, 0.2)
+	expected := []Regression{{"slow/1000", "allocs/op", 100, 130}}
+	if !reflect.DeepEqual(regressions, expected) {
+		t.Errorf("bad regressions: %v", regressions)
+	}
+	if !reflect.DeepEqual(missing, []string{"scan/1000"}) {
+		t.Errorf("bad missing keys: %v", missing)
+	}
+	if regressions, _ := compareBaseline(baseline, current, 0.1); len(regressions) != 2 {
+		t.Errorf("expected 2 regressions with 10%% threshold")
+	}
+}
+
 // -----
 // go test -bench . -benchmem
 
@@ -44,3 +561,9 @@ func BenchmarkFast(b *testing.B) {
 		FastSearch(ioutil.Discard)
 	}
 }
+
+func BenchmarkScan(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		ScanSearch(ioutil.Discard)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// SearchSummary - data known only after the whole file is read
+type SearchSummary struct {
+	UniqueBrowsers int
+	Rejected       []*LineError
+}
+
+// UserFormatter - writes search results as soon as users are found
+type UserFormatter interface {
+	Header() error
+	User(id int, name, email []byte) error
+	Footer(summary SearchSummary) error
+}
+
+// NewFormatter - creates formatter writing into out
+type NewFormatter func(out io.Writer) UserFormatter
+
+// textFormatter - the original "[id] name <email>" output
+type textFormatter struct {
+	out  io.Writer
+	line []byte
+}
+
+func NewTextFormatter(out io.Writer) UserFormatter {
+	return &textFormatter{out: out, line: make([]byte, 0, 256)}
+}
+
+func (f *textFormatter) Header() error {
+	_, err := io.WriteString(f.out, "found users:\n")
+	return err
+}
+
+func (f *textFormatter) User(id int, name, email []byte) error {
+	f.line = appendUserLine(f.line[:0], id, name, email)
+	_, err := f.out.Write(f.line)
+	return err
+}
+
+func (f *textFormatter) Footer(summary SearchSummary) error {
+	if _, err := fmt.Fprintln(f.out, "\nTotal unique browsers", summary.UniqueBrowsers); err != nil {
+		return err
+	}
+	return writeRejected(f.out, "", summary.Rejected)
+}
+
+// jsonLinesFormatter - one json object per found user, summary object goes last
+type jsonLinesFormatter struct {
+	enc *json.Encoder
+}
+
+type jsonUser struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type jsonRejected struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+type jsonSummary struct {
+	UniqueBrowsers int            `json:"total_unique_browsers"`
+	Rejected       []jsonRejected `json:"rejected,omitempty"`
+}
+
+func NewJSONLinesFormatter(out io.Writer) UserFormatter {
+	return &jsonLinesFormatter{enc: json.NewEncoder(out)}
+}
+
+func (f *jsonLinesFormatter) Header() error {
+	return nil
+}
+
+func (f *jsonLinesFormatter) User(id int, name, email []byte) error {
+	return f.enc.Encode(jsonUser{id, string(name), string(email)})
+}
+
+func (f *jsonLinesFormatter) Footer(summary SearchSummary) error {
+	s := jsonSummary{UniqueBrowsers: summary.UniqueBrowsers}
+	for _, e := range summary.Rejected {
+		s.Rejected = append(s.Rejected, jsonRejected{e.Line, e.Err.Error()})
+	}
+	return f.enc.Encode(s)
+}
+
+// csvFormatter - id,name,email rows, summary goes into # comment lines
+type csvFormatter struct {
+	out io.Writer
+	w   *csv.Writer
+	row []string
+}
+
+func NewCSVFormatter(out io.Writer) UserFormatter {
+	return &csvFormatter{out: out, w: csv.NewWriter(out), row: make([]string, 3)}
+}
+
+func (f *csvFormatter) Header() error {
+	return f.w.Write([]string{"id", "name", "email"})
+}
+
+func (f *csvFormatter) User(id int, name, email []byte) error {
+	f.row[0] = strconv.Itoa(id)
+	f.row[1] = string(name)
+	f.row[2] = string(email)
+	return f.w.Write(f.row)
+}
+
+func (f *csvFormatter) Footer(summary SearchSummary) error {
+	f.w.Flush()
+	if err := f.w.Error(); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f.out, "# Total unique browsers", summary.UniqueBrowsers); err != nil {
+		return err
+	}
+	return writeRejected(f.out, "# ", summary.Rejected)
+}
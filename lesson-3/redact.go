@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"unicode/utf8"
+)
+
+// FieldPolicy - how user field is shown in search output
+type FieldPolicy int
+
+const (
+	// PolicyDefault - email is obfuscated, name is kept, as search always did
+	PolicyDefault FieldPolicy = iota
+	// PolicyKeep - value as is, only for authorized runs
+	PolicyKeep
+	// PolicyObfuscate - @ is replaced with [at]
+	PolicyObfuscate
+	// PolicyHash - first 16 hex chars of hmac-sha256 of value keyed by salt
+	PolicyHash
+	// PolicyMask - only the first letter of every word is kept, for emails domain is kept too
+	PolicyMask
+	// PolicyDrop - empty value
+	PolicyDrop
+)
+
+var policyNames = map[string]FieldPolicy{
+	"default":   PolicyDefault,
+	"keep":      PolicyKeep,
+	"obfuscate": PolicyObfuscate,
+	"hash":      PolicyHash,
+	"mask":      PolicyMask,
+	"drop":      PolicyDrop,
+}
+
+// ParseFieldPolicy - policy by its name: keep, obfuscate, hash, mask or drop
+func ParseFieldPolicy(name string) (FieldPolicy, error) {
+	policy, ok := policyNames[name]
+	if !ok {
+		return PolicyDefault, fmt.Errorf("unknown redaction policy %q", name)
+	}
+	return policy, nil
+}
+
+// Redaction - policies for user fields in search output.
+// Zero value gives the original output
+type Redaction struct {
+	Email FieldPolicy
+	Name  FieldPolicy
+	// Salt - key of hmac for hashed values, required by PolicyHash:
+	// without it hashes of known emails are easy to guess
+	Salt []byte
+}
+
+// ErrNoSalt - PolicyHash is used without Salt
+var ErrNoSalt = errors.New("hash redaction policy requires salt")
+
+const hashLen = 16
+
+var maskBytes = []byte("***")
+
+// redactor - applies Redaction, keeps hasher between calls to avoid allocations
+type redactor struct {
+	Redaction
+	h   hash.Hash
+	sum []byte
+}
+
+func (r Redaction) newRedactor() (*redactor, error) {
+	if (r.Email == PolicyHash || r.Name == PolicyHash) && len(r.Salt) == 0 {
+		return nil, ErrNoSalt
+	}
+	return &redactor{Redaction: r}, nil
+}
+
+// email - append email according to Email policy
+func (r *redactor) email(dst, email []byte) []byte {
+	switch r.Email {
+	case PolicyDefault, PolicyObfuscate:
+		return appendObfuscated(dst, email)
+	case PolicyMask:
+		at := bytes.LastIndexByte(email, '@')
+		if at < 0 {
+			return appendMasked(dst, email)
+		}
+		dst = appendMasked(dst, email[:at])
+		return append(dst, email[at:]...)
+	default:
+		return r.apply(dst, email, r.Email)
+	}
+}
+
+// name - append name according to Name policy
+func (r *redactor) name(dst, name []byte) []byte {
+	switch r.Name {
+	case PolicyDefault:
+		return append(dst, name...)
+	case PolicyObfuscate:
+		return appendObfuscated(dst, name)
+	case PolicyMask:
+		return appendMasked(dst, name)
+	default:
+		return r.apply(dst, name, r.Name)
+	}
+}
+
+func (r *redactor) apply(dst, value []byte, policy FieldPolicy) []byte {
+	switch policy {
+	case PolicyHash:
+		if r.h == nil {
+			r.h = hmac.New(sha256.New, r.Salt)
+		}
+		r.h.Reset()
+		r.h.Write(value)
+		r.sum = r.h.Sum(r.sum[:0])
+		var tmp [hashLen]byte
+		hex.Encode(tmp[:], r.sum[:hashLen/2])
+		return append(dst, tmp[:]...)
+	case PolicyDrop:
+		return dst
+	default:
+		return append(dst, value...)
+	}
+}
+
+// appendMasked - keep the first letter of every space separated word, "John Smith" -> "J*** S***"
+func appendMasked(dst, value []byte) []byte {
+	word := true
+	for len(value) > 0 {
+		r, size := utf8.DecodeRune(value)
+		switch {
+		case r == ' ':
+			dst = append(dst, ' ')
+			word = true
+		case word:
+			dst = append(dst, value[:size]...)
+			dst = append(dst, maskBytes...)
+			word = false
+		}
+		value = value[size:]
+	}
+	return dst
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	ReportFormatTable = "table"
+	ReportFormatCSV   = "csv"
+	ReportFormatJSON  = "json"
+)
+
+// browserFamilies - markers of browser families, checked in order.
+// Version is read right after the marker
+var browserFamilies = []struct {
+	Family string
+	Marker []byte
+}{
+	{"Opera", []byte("Opera/")},
+	{"MSIE", []byte("MSIE ")},
+	{"Android", []byte("Android ")},
+	{"Firefox", []byte("Firefox/")},
+	{"Chrome", []byte("Chrome/")},
+	{"Safari", []byte("Safari/")},
+}
+
+const otherFamily = "Other"
+
+// BrowserStat - usage of one browser family and major version
+type BrowserStat struct {
+	Family  string `json:"family"`
+	Version string `json:"version"`
+	Users   int    `json:"users"`
+	Unique  int    `json:"unique"`
+
+	seen seenBrowsers
+}
+
+// DomainStat - users count for email domain
+type DomainStat struct {
+	Domain string `json:"domain"`
+	Users  int    `json:"users"`
+}
+
+// ComboStat - users count for set of browser families used together
+type ComboStat struct {
+	Browsers string `json:"browsers"`
+	Users    int    `json:"users"`
+}
+
+// Report - aggregates collected in one pass over users file
+type Report struct {
+	TotalUsers int `json:"total_users"`
+	// UniqueUserAgents - distinct browsers of all families, not only Android and MSIE ones searches count
+	UniqueUserAgents int            `json:"unique_user_agents"`
+	Browsers         []*BrowserStat `json:"browsers"`
+	Domains          []DomainStat   `json:"domains"`
+	Combinations     []ComboStat    `json:"combinations"`
+}
+
+// browserFamily - detect family and major version of user agent
+func browserFamily(browser []byte) (string, string) {
+	for _, f := range browserFamilies {
+		idx := bytes.Index(browser, f.Marker)
+		if idx < 0 {
+			continue
+		}
+		version := browser[idx+len(f.Marker):]
+		end := 0
+		for end < len(version) && version[end] >= '0' && version[end] <= '9' {
+			end++
+		}
+		return f.Family, string(version[:end])
+	}
+	return otherFamily, ""
+}
+
+// BuildReport - read users line by line and collect browser, domain and combination stats.
+// Only topN most popular domains are kept, topN <= 0 keeps all of them
+func BuildReport(in io.Reader, topN int) (*Report, error) {
+	report := &Report{}
+	var seen seenBrowsers
+	browsers := map[string]*BrowserStat{}
+	domains := map[string]int{}
+	combos := map[string]int{}
+	user := lineUser{}
+	families := make([]string, 0, len(browserFamilies)+1)
+	var userKeys []string
+
+	scanner := newLineScanner(in)
+	line := 0
+	for scanner.Scan() {
+		line++
+		err := scanner.LineErr()
+		if err == nil {
+			err = user.parse(scanner.Bytes())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		report.TotalUsers++
+
+		families = families[:0]
+		userKeys = userKeys[:0]
+		for _, browser := range user.Browsers {
+			seen.addBytes(browser)
+
+			family, version := browserFamily(browser)
+			key := family + " " + version
+			stat, ok := browsers[key]
+			if !ok {
+				stat = &BrowserStat{Family: family, Version: version}
+				browsers[key] = stat
+			}
+			// user is counted once per family and version
+			before := len(userKeys)
+			userKeys = appendUnique(userKeys, key)
+			if len(userKeys) > before {
+				stat.Users++
+			}
+			if stat.seen.addBytes(browser) {
+				stat.Unique++
+			}
+			families = appendUnique(families, family)
+		}
+
+		if at := bytes.LastIndexByte(user.Email, '@'); at >= 0 {
+			domains[strings.ToLower(string(user.Email[at+1:]))]++
+		}
+
+		sort.Strings(families)
+		combo := strings.Join(families, "+")
+		if combo == "" {
+			combo = "none"
+		}
+		combos[combo]++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	report.UniqueUserAgents = seen.len()
+	for _, stat := range browsers {
+		report.Browsers = append(report.Browsers, stat)
+	}
+	sort.Slice(report.Browsers, func(i, j int) bool {
+		a, b := report.Browsers[i], report.Browsers[j]
+		if a.Family != b.Family {
+			return a.Family < b.Family
+		}
+		av, _ := strconv.Atoi(a.Version)
+		bv, _ := strconv.Atoi(b.Version)
+		if av != bv {
+			return av < bv
+		}
+		// "" and "0", "8" and "08" are the same number
+		return a.Version < b.Version
+	})
+
+	for domain, users := range domains {
+		report.Domains = append(report.Domains, DomainStat{domain, users})
+	}
+	sort.Slice(report.Domains, func(i, j int) bool {
+		a, b := report.Domains[i], report.Domains[j]
+		if a.Users != b.Users {
+			return a.Users > b.Users
+		}
+		return a.Domain < b.Domain
+	})
+	if topN > 0 && len(report.Domains) > topN {
+		report.Domains = report.Domains[:topN]
+	}
+
+	for combo, users := range combos {
+		report.Combinations = append(report.Combinations, ComboStat{combo, users})
+	}
+	sort.Slice(report.Combinations, func(i, j int) bool {
+		a, b := report.Combinations[i], report.Combinations[j]
+		if a.Users != b.Users {
+			return a.Users > b.Users
+		}
+		return a.Browsers < b.Browsers
+	})
+
+	return report, nil
+}
+
+// appendUnique - append value if slice does not contain it yet
+func appendUnique(arr []string, value string) []string {
+	for _, v := range arr {
+		if v == value {
+			return arr
+		}
+	}
+	return append(arr, value)
+}
+
+// Write - print report in one of ReportFormat* formats
+func (r *Report) Write(out io.Writer, format string) error {
+	switch format {
+	case ReportFormatTable, "":
+		return r.writeTable(out)
+	case ReportFormatCSV:
+		return r.writeCSV(out)
+	case ReportFormatJSON:
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+}
+
+func (r *Report) writeTable(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "Total users\t", r.TotalUsers)
+	fmt.Fprintln(w, "Total unique user agents\t", r.UniqueUserAgents)
+
+	fmt.Fprintln(w, "\nFAMILY\tVERSION\tUSERS\tUNIQUE")
+	for _, b := range r.Browsers {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", b.Family, b.Version, b.Users, b.Unique)
+	}
+
+	fmt.Fprintln(w, "\nDOMAIN\tUSERS")
+	for _, d := range r.Domains {
+		fmt.Fprintf(w, "%s\t%d\n", d.Domain, d.Users)
+	}
+
+	fmt.Fprintln(w, "\nBROWSERS\tUSERS")
+	for _, c := range r.Combinations {
+		fmt.Fprintf(w, "%s\t%d\n", c.Browsers, c.Users)
+	}
+	return w.Flush()
+}
+
+// writeCSV - all sections go into one table, the first column tells which section the row belongs to
+func (r *Report) writeCSV(out io.Writer) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"section", "name", "version", "users", "unique"})
+	w.Write([]string{"total", "users", "", strconv.Itoa(r.TotalUsers), ""})
+	w.Write([]string{"total", "user agents", "", "", strconv.Itoa(r.UniqueUserAgents)})
+	for _, b := range r.Browsers {
+		w.Write([]string{"browser", b.Family, b.Version, strconv.Itoa(b.Users), strconv.Itoa(b.Unique)})
+	}
+	for _, d := range r.Domains {
+		w.Write([]string{"domain", d.Domain, "", strconv.Itoa(d.Users), ""})
+	}
+	for _, c := range r.Combinations {
+		w.Write([]string{"combination", c.Browsers, "", strconv.Itoa(c.Users), ""})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// ReportSearch - build report for users file of opts.Path and print it.
+// Report counts every user, so a bad line fails it in any opts.Mode
+func ReportSearch(out io.Writer, opts SearchOptions, format string, topN int) error {
+	file, err := os.Open(opts.path())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	report, err := BuildReport(file, topN)
+	if err != nil {
+		return err
+	}
+	return report.Write(out, format)
+}
@@ -0,0 +1,534 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var (
+	androidBytes = []byte("Android")
+	msieBytes    = []byte("MSIE")
+	atBytes      = []byte(" [at] ")
+)
+
+// lineUser - user fields pulled straight from the line bytes.
+// Slices point into the parsed line or into buf and are valid until the next parse call
+type lineUser struct {
+	Browsers [][]byte
+	Email    []byte
+	Name     []byte
+	buf      []byte
+}
+
+// parse - fill user from json line, all fields except browsers, email and name are skipped
+func (u *lineUser) parse(line []byte) error {
+	u.Browsers = u.Browsers[:0]
+	u.Email = nil
+	u.Name = nil
+	// unescaped value is never longer than the escaped one, so buf never grows during parse
+	// and slices taken from it stay valid
+	if cap(u.buf) < len(line) {
+		u.buf = make([]byte, 0, len(line))
+	}
+	u.buf = u.buf[:0]
+
+	p := lineParser{data: line}
+	if err := p.want('{'); err != nil {
+		return err
+	}
+	if p.peek() == '}' {
+		p.pos++
+		return p.end()
+	}
+	for {
+		key, err := p.str(u)
+		if err != nil {
+			return err
+		}
+		if err = p.want(':'); err != nil {
+			return err
+		}
+		switch string(key) {
+		case "browsers":
+			err = p.browsers(u)
+		case "email":
+			u.Email, err = p.nullableStr(u)
+		case "name":
+			u.Name, err = p.nullableStr(u)
+		default:
+			err = p.skip()
+		}
+		if err != nil {
+			return err
+		}
+		p.space()
+		if p.pos >= len(p.data) {
+			return p.errorf("unexpected end of line")
+		}
+		switch p.data[p.pos] {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return p.end()
+		default:
+			return p.errorf("unexpected %q after object value", p.data[p.pos])
+		}
+	}
+}
+
+// lineParser - cursor over a single json line
+type lineParser struct {
+	data []byte
+	pos  int
+}
+
+func (p *lineParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("offset %d: "+format, append([]interface{}{p.pos}, args...)...)
+}
+
+// space - skip whitespaces
+func (p *lineParser) space() {
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case ' ', '\t', '\r', '\n':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+// peek - return next non space byte without consuming it, 0 at the end of line
+func (p *lineParser) peek() byte {
+	p.space()
+	if p.pos >= len(p.data) {
+		return 0
+	}
+	return p.data[p.pos]
+}
+
+// want - consume expected delimiter
+func (p *lineParser) want(c byte) error {
+	if got := p.peek(); got != c {
+		if got == 0 {
+			return p.errorf("expected %q, got end of line", c)
+		}
+		return p.errorf("expected %q, got %q", c, got)
+	}
+	p.pos++
+	return nil
+}
+
+// end - check that nothing except spaces is left in the line
+func (p *lineParser) end() error {
+	p.space()
+	if p.pos != len(p.data) {
+		return p.errorf("unexpected data after top-level object")
+	}
+	return nil
+}
+
+// browsers - read array of strings into u.Browsers
+func (p *lineParser) browsers(u *lineUser) error {
+	if p.peek() == 'n' {
+		return p.literal("null")
+	}
+	if err := p.want('['); err != nil {
+		return err
+	}
+	if p.peek() == ']' {
+		p.pos++
+		return nil
+	}
+	for {
+		if p.peek() != '"' {
+			// search works with strings only, other items are skipped
+			if err := p.skip(); err != nil {
+				return err
+			}
+		} else {
+			browser, err := p.str(u)
+			if err != nil {
+				return err
+			}
+			u.Browsers = append(u.Browsers, browser)
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return nil
+		default:
+			return p.errorf("unexpected end of array")
+		}
+	}
+}
+
+// nullableStr - read string value, null gives nil
+func (p *lineParser) nullableStr(u *lineUser) ([]byte, error) {
+	if p.peek() == 'n' {
+		return nil, p.literal("null")
+	}
+	return p.str(u)
+}
+
+// str - read string, escaped strings are decoded into u.buf
+func (p *lineParser) str(u *lineUser) ([]byte, error) {
+	raw, escaped, err := p.rawStr()
+	if err != nil || !escaped {
+		return raw, err
+	}
+	return u.unescape(raw)
+}
+
+// rawStr - read string without decoding it, escaped is true if it has escape sequences.
+// Escapes and control characters are checked here, so unescape gets valid string only
+func (p *lineParser) rawStr() (raw []byte, escaped bool, err error) {
+	if err := p.want('"'); err != nil {
+		return nil, false, err
+	}
+	start := p.pos
+	for p.pos < len(p.data) {
+		switch c := p.data[p.pos]; {
+		case c == '"':
+			raw = p.data[start:p.pos]
+			p.pos++
+			return raw, escaped, nil
+		case c == '\\':
+			escaped = true
+			p.pos++
+			if p.pos >= len(p.data) {
+				return nil, false, p.errorf("unterminated string")
+			}
+			switch p.data[p.pos] {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+				p.pos++
+			case 'u':
+				if _, ok := hexRune(p.data[p.pos+1:]); !ok {
+					return nil, false, p.errorf("invalid unicode escape")
+				}
+				p.pos += 5
+			default:
+				return nil, false, p.errorf("invalid escape %q", p.data[p.pos])
+			}
+		case c < 0x20:
+			return nil, false, p.errorf("control character %q in string", c)
+		default:
+			p.pos++
+		}
+	}
+	return nil, false, p.errorf("unterminated string")
+}
+
+// literal - consume true/false/null
+func (p *lineParser) literal(lit string) error {
+	if !bytes.HasPrefix(p.data[p.pos:], []byte(lit)) {
+		return p.errorf("invalid literal")
+	}
+	p.pos += len(lit)
+	return nil
+}
+
+// skip - skip any json value without decoding it, value is still validated
+// so lines rejected by encoding/json are rejected here too
+func (p *lineParser) skip() error {
+	switch c := p.peek(); {
+	case c == '"':
+		_, _, err := p.rawStr()
+		return err
+	case c == '{':
+		return p.skipObject()
+	case c == '[':
+		return p.skipArray()
+	case c == 't':
+		return p.literal("true")
+	case c == 'f':
+		return p.literal("false")
+	case c == 'n':
+		return p.literal("null")
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.number()
+	case c == 0:
+		return p.errorf("unexpected end of line")
+	default:
+		return p.errorf("unexpected %q", c)
+	}
+}
+
+// skipObject - skip object with all its members
+func (p *lineParser) skipObject() error {
+	if err := p.want('{'); err != nil {
+		return err
+	}
+	if p.peek() == '}' {
+		p.pos++
+		return nil
+	}
+	for {
+		if _, _, err := p.rawStr(); err != nil {
+			return err
+		}
+		if err := p.want(':'); err != nil {
+			return err
+		}
+		if err := p.skip(); err != nil {
+			return err
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return nil
+		default:
+			return p.errorf("unterminated object")
+		}
+	}
+}
+
+// skipArray - skip array with all its items
+func (p *lineParser) skipArray() error {
+	if err := p.want('['); err != nil {
+		return err
+	}
+	if p.peek() == ']' {
+		p.pos++
+		return nil
+	}
+	for {
+		if err := p.skip(); err != nil {
+			return err
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return nil
+		default:
+			return p.errorf("unterminated array")
+		}
+	}
+}
+
+// number - consume number: -?(0|[1-9][0-9]*)(.[0-9]+)?([eE][+-]?[0-9]+)?
+func (p *lineParser) number() error {
+	if p.pos < len(p.data) && p.data[p.pos] == '-' {
+		p.pos++
+	}
+	switch {
+	case p.pos < len(p.data) && p.data[p.pos] == '0':
+		p.pos++
+	case !p.digits():
+		return p.errorf("invalid number")
+	}
+	if p.pos < len(p.data) && p.data[p.pos] == '.' {
+		p.pos++
+		if !p.digits() {
+			return p.errorf("invalid number")
+		}
+	}
+	if p.pos < len(p.data) && (p.data[p.pos] == 'e' || p.data[p.pos] == 'E') {
+		p.pos++
+		if p.pos < len(p.data) && (p.data[p.pos] == '+' || p.data[p.pos] == '-') {
+			p.pos++
+		}
+		if !p.digits() {
+			return p.errorf("invalid number")
+		}
+	}
+	return nil
+}
+
+// digits - consume run of digits, false if there is none
+func (p *lineParser) digits() bool {
+	start := p.pos
+	for p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '9' {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+// unescape - decode escaped string into u.buf
+func (u *lineUser) unescape(raw []byte) ([]byte, error) {
+	start := len(u.buf)
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if c != '\\' {
+			u.buf = append(u.buf, c)
+			continue
+		}
+		i++
+		if i >= len(raw) {
+			return nil, fmt.Errorf("invalid escape at the end of string")
+		}
+		switch raw[i] {
+		case '"', '\\', '/':
+			u.buf = append(u.buf, raw[i])
+		case 'b':
+			u.buf = append(u.buf, '\b')
+		case 'f':
+			u.buf = append(u.buf, '\f')
+		case 'n':
+			u.buf = append(u.buf, '\n')
+		case 'r':
+			u.buf = append(u.buf, '\r')
+		case 't':
+			u.buf = append(u.buf, '\t')
+		case 'u':
+			r, ok := hexRune(raw[i+1:])
+			if !ok {
+				return nil, fmt.Errorf("invalid unicode escape")
+			}
+			i += 4
+			if utf16.IsSurrogate(r) {
+				r2, ok := rune(0), false
+				if i+2 < len(raw) && raw[i+1] == '\\' && raw[i+2] == 'u' {
+					r2, ok = hexRune(raw[i+3:])
+				}
+				if dec := utf16.DecodeRune(r, r2); ok && dec != utf8.RuneError {
+					r = dec
+					i += 6
+				} else {
+					r = utf8.RuneError
+				}
+			}
+			var tmp [utf8.UTFMax]byte
+			n := utf8.EncodeRune(tmp[:], r)
+			u.buf = append(u.buf, tmp[:n]...)
+		default:
+			return nil, fmt.Errorf("invalid escape %q", raw[i])
+		}
+	}
+	return u.buf[start:len(u.buf):len(u.buf)], nil
+}
+
+// hexRune - parse 4 hex digits of \u escape
+func hexRune(b []byte) (rune, bool) {
+	if len(b) < 4 {
+		return 0, false
+	}
+	var r rune
+	for _, c := range b[:4] {
+		switch {
+		case c >= '0' && c <= '9':
+			c -= '0'
+		case c >= 'a' && c <= 'f':
+			c = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			c = c - 'A' + 10
+		default:
+			return 0, false
+		}
+		r = r<<4 | rune(c)
+	}
+	return r, true
+}
+
+// appendUserLine - append "[id] name <email>" line
+func appendUserLine(line []byte, id int, name, email []byte) []byte {
+	line = append(line, '[')
+	line = strconv.AppendInt(line, int64(id), 10)
+	line = append(line, "] "...)
+	line = append(line, name...)
+	line = append(line, " <"...)
+	line = append(line, email...)
+	return append(line, ">\n"...)
+}
+
+// appendObfuscated - append email with @ replaced by [at]
+func appendObfuscated(dst, email []byte) []byte {
+	for at := bytes.IndexByte(email, '@'); at >= 0; at = bytes.IndexByte(email, '@') {
+		dst = append(dst, email[:at]...)
+		dst = append(dst, atBytes...)
+		email = email[at+1:]
+	}
+	return append(dst, email...)
+}
+
+// ScanSearch - same search as FastSearch, but without easyjson and without allocations per line
+func ScanSearch(out io.Writer) {
+	if _, err := ScanSearchWith(out, SearchOptions{}); err != nil {
+		panic(err)
+	}
+}
+
+// ScanSearchWith - ScanSearch which handles bad lines according to opts.Mode
+func ScanSearchWith(out io.Writer, opts SearchOptions) ([]*LineError, error) {
+	red, err := opts.Redaction.newRedactor()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(opts.path())
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var seen seenBrowsers
+	var rejected []*LineError
+	user := lineUser{}
+	name := make([]byte, 0, 64)
+	email := make([]byte, 0, 64)
+
+	w := bufio.NewWriter(out)
+	format := opts.formatter(w)
+	if err := format.Header(); err != nil {
+		return nil, err
+	}
+
+	scanner := newLineScanner(file)
+	i := -1
+	for scanner.Scan() {
+		i++
+		err := scanner.LineErr()
+		if err == nil {
+			err = user.parse(scanner.Bytes())
+		}
+		if err != nil {
+			if err := opts.reject(&rejected, i+1, err); err != nil {
+				return rejected, err
+			}
+			continue
+		}
+
+		var isAndroid bool
+		var isMSIE bool
+
+		for _, browser := range user.Browsers {
+			isAndroidLocal := bytes.Contains(browser, androidBytes)
+			isMSIELocal := bytes.Contains(browser, msieBytes)
+			if isAndroidLocal || isMSIELocal {
+				seen.addBytes(browser)
+			}
+			if isAndroidLocal {
+				isAndroid = true
+			}
+			if isMSIELocal {
+				isMSIE = true
+			}
+		}
+		if isAndroid && isMSIE {
+			name = red.name(name[:0], user.Name)
+			email = red.email(email[:0], user.Email)
+			if err := format.User(i, name, email); err != nil {
+				return rejected, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return rejected, err
+	}
+	if err := format.Footer(SearchSummary{seen.len(), rejected}); err != nil {
+		return rejected, err
+	}
+	return rejected, w.Flush()
+}
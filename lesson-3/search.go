@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// ParseMode - what to do with malformed json lines
+type ParseMode int
+
+const (
+	// ParseStrict - stop on the first bad line and return *LineError
+	ParseStrict ParseMode = iota
+	// ParseLenient - skip bad lines, collect them and print summary after results
+	ParseLenient
+)
+
+// SearchOptions - settings shared by SlowSearchWith, FastSearchWith and ScanSearchWith
+type SearchOptions struct {
+	Mode ParseMode
+	// Path - users file, filePath if empty
+	Path string
+	// Formatter - output of found users, NewTextFormatter if nil
+	Formatter NewFormatter
+	// Redaction - how emails and names are shown, zero value obfuscates emails only
+	Redaction Redaction
+}
+
+func (opts SearchOptions) path() string {
+	if opts.Path == "" {
+		return filePath
+	}
+	return opts.Path
+}
+
+func (opts SearchOptions) formatter(out io.Writer) UserFormatter {
+	if opts.Formatter == nil {
+		return NewTextFormatter(out)
+	}
+	return opts.Formatter(out)
+}
+
+// SearchFunc - search of Android and MSIE users in file of opts, returns lines skipped in lenient mode
+type SearchFunc func(out io.Writer, opts SearchOptions) ([]*LineError, error)
+
+// searches - implementations of the same search by name, for benchmark harness and tests
+var searches = map[string]SearchFunc{
+	"slow": SlowSearchWith,
+	"fast": FastSearchWith,
+	"scan": ScanSearchWith,
+}
+
+// LineError - line of users file which can not be parsed
+type LineError struct {
+	Line int // starts from 1
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// reject - handle bad line according to parse mode.
+// Returns error which must stop the search, nil means line is skipped
+func (opts SearchOptions) reject(rejected *[]*LineError, line int, err error) error {
+	lineErr := &LineError{Line: line, Err: err}
+	if opts.Mode == ParseStrict {
+		return lineErr
+	}
+	*rejected = append(*rejected, lineErr)
+	return nil
+}
+
+// writeRejected - print summary of skipped lines, every line starts with prefix.
+// Nothing is printed if all lines were fine
+func writeRejected(out io.Writer, prefix string, rejected []*LineError) error {
+	if len(rejected) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintln(out, prefix+"Rejected lines", len(rejected)); err != nil {
+		return err
+	}
+	for _, e := range rejected {
+		if _, err := fmt.Fprintln(out, prefix+e.Error()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lineScanner - reads lines like bufio.Scanner, but line longer than the buffer does not stop it:
+// such line is skipped and LineErr returns bufio.ErrTooLong, so lenient mode can reject it and go on
+type lineScanner struct {
+	r       *bufio.Reader
+	line    []byte
+	lineErr error
+	err     error
+	offset  int64
+}
+
+func newLineScanner(r io.Reader) *lineScanner {
+	return &lineScanner{r: bufio.NewReaderSize(r, bufio.MaxScanTokenSize)}
+}
+
+// Scan - advance to the next line, false at the end of input or on read error.
+// As with bufio.Scanner, newline at the end of input does not give empty line
+func (s *lineScanner) Scan() bool {
+	s.line, s.lineErr = nil, nil
+	if s.err != nil {
+		return false
+	}
+	line, err := s.r.ReadSlice('\n')
+	s.offset += int64(len(line))
+	for err == bufio.ErrBufferFull {
+		s.lineErr = bufio.ErrTooLong
+		line, err = s.r.ReadSlice('\n')
+		s.offset += int64(len(line))
+	}
+	if err != nil {
+		s.err = err
+		if err != io.EOF || len(line) == 0 && s.lineErr == nil {
+			return false
+		}
+	}
+	if s.lineErr != nil {
+		return true
+	}
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	s.line = bytes.TrimSuffix(line, []byte("\r"))
+	return true
+}
+
+// Bytes - current line without line ending, valid until the next Scan
+func (s *lineScanner) Bytes() []byte {
+	return s.line
+}
+
+// Offset - bytes of input read up to the end of the current line, including line ending
+func (s *lineScanner) Offset() int64 {
+	return s.offset
+}
+
+// LineErr - why current line can not be read, the scan still goes on
+func (s *lineScanner) LineErr() error {
+	return s.lineErr
+}
+
+// Err - read error which stopped the scan, nil at the end of input
+func (s *lineScanner) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// errNoRefresh - у провайдера нет способа получить новые данные авторизации
+var errNoRefresh = errors.New("auth provider can not refresh")
+
+// AuthProvider - добавляет в запрос данные авторизации
+type AuthProvider interface {
+	// Apply - вызывается перед каждым запросом, в том числе перед повторами
+	Apply(ctx context.Context, r *http.Request) error
+	// Refresh - вызывается после 401, после успешного Refresh запрос повторяется один раз
+	Refresh(ctx context.Context) error
+}
+
+// StaticToken - постоянный токен в заголовке, так работает SearchClient.AccessToken
+type StaticToken struct {
+	Token string
+	// Header - заголовок для токена, по умолчанию AccessToken
+	Header string
+}
+
+func (a *StaticToken) Apply(ctx context.Context, r *http.Request) error {
+	header := a.Header
+	if header == "" {
+		header = "AccessToken"
+	}
+	r.Header.Set(header, a.Token)
+	return nil
+}
+
+func (a *StaticToken) Refresh(ctx context.Context) error {
+	return errNoRefresh
+}
+
+// BearerToken - токен в Authorization: Bearer, который получается через Fetch и обновляется до истечения.
+// Одновременные обновления ждут один вызов Fetch, mu во время Fetch не держится
+type BearerToken struct {
+	// Fetch - получает новый токен и время, до которого он действителен, нулевое время - бессрочный
+	Fetch func(ctx context.Context) (token string, expiry time.Time, err error)
+	// Leeway - за сколько до истечения токен обновляется заранее
+	Leeway time.Duration
+
+	mu         sync.Mutex
+	token      string
+	expiry     time.Time
+	refreshing *tokenCall
+}
+
+// tokenCall - вызов Fetch, который ждут одновременные обновления
+type tokenCall struct {
+	done chan struct{}
+	err  error
+}
+
+func (a *BearerToken) Apply(ctx context.Context, r *http.Request) error {
+	a.mu.Lock()
+	expired := a.token == "" || (!a.expiry.IsZero() && time.Now().Add(a.Leeway).After(a.expiry))
+	a.mu.Unlock()
+	if expired {
+		if err := a.Refresh(ctx); err != nil {
+			return err
+		}
+	}
+	a.mu.Lock()
+	token := a.token
+	a.mu.Unlock()
+	r.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Refresh - вызывает Fetch или, если он уже выполняется, ждет его результата.
+// Fetch идет с контекстом без отмены: его результат ждут и другие вызовы, поэтому отмена ctx
+// прерывает только ожидание этого вызова
+func (a *BearerToken) Refresh(ctx context.Context) error {
+	a.mu.Lock()
+	call := a.refreshing
+	if call == nil {
+		call = &tokenCall{done: make(chan struct{})}
+		a.refreshing = call
+		go a.fetch(context.WithoutCancel(ctx), call)
+	}
+	a.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *BearerToken) fetch(ctx context.Context, call *tokenCall) {
+	token, expiry, err := a.Fetch(ctx)
+
+	a.mu.Lock()
+	if err == nil {
+		a.token, a.expiry = token, expiry
+	}
+	a.refreshing = nil
+	a.mu.Unlock()
+
+	call.err = err
+	close(call.done)
+}
+
+// HMACSigner - подпись запроса общим секретом вместо токена.
+// Подписываются метод, путь с параметрами и время: X-Signature = hex(hmac-sha256(Secret, "METHOD\nURI\nTIMESTAMP"))
+type HMACSigner struct {
+	KeyID  string
+	Secret []byte
+	// Now - источник времени для X-Signature-Timestamp, по умолчанию time.Now
+	Now func() time.Time
+}
+
+func (a *HMACSigner) Apply(ctx context.Context, r *http.Request) error {
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+	timestamp := strconv.FormatInt(now().Unix(), 10)
+	r.Header.Set("X-Signature-Key", a.KeyID)
+	r.Header.Set("X-Signature-Timestamp", timestamp)
+	r.Header.Set("X-Signature", a.sign(r.Method, r.URL.RequestURI(), timestamp))
+	return nil
+}
+
+func (a *HMACSigner) Refresh(ctx context.Context) error {
+	return errNoRefresh
+}
+
+func (a *HMACSigner) sign(method, uri, timestamp string) string {
+	mac := hmac.New(sha256.New, a.Secret)
+	mac.Write([]byte(method + "\n" + uri + "\n" + timestamp))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Cache - кэш ответов FindUsers по нормализованному запросу.
+// Ответ живет TTL, при переполнении вытесняется давно не используемый.
+// Одновременные одинаковые запросы ждут один поход во внешнюю систему.
+// Устаревший ответ с ETag перепроверяется через If-None-Match вместо повторной загрузки.
+// Ответы не разделяются по токенам, поэтому один Cache можно использовать только для клиентов с одинаковыми правами.
+// Создается через NewCache
+type Cache struct {
+	TTL time.Duration
+	// MaxEntries - сколько ответов хранить, 0 - без ограничения
+	MaxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	calls   map[string]*cacheCall
+}
+
+type cacheEntry struct {
+	key     string
+	resp    *SearchResponse
+	expires time.Time
+}
+
+// cacheCall - запрос во внешнюю систему, который ждут одинаковые FindUsers
+type cacheCall struct {
+	done chan struct{}
+	resp *SearchResponse
+	err  error
+	// cancelled - fetch выполнялся с ctx первого запроса, и тот отменили или у него вышел срок.
+	// Ошибка тогда относится только к нему, остальные повторяют запрос сами
+	cancelled bool
+}
+
+func NewCache(ttl time.Duration, maxEntries int) *Cache {
+	return &Cache{
+		TTL:        ttl,
+		MaxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+		calls:      map[string]*cacheCall{},
+	}
+}
+
+// Len - сколько ответов сейчас в кэше, включая устаревшие с ETag
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Purge - удаляет все ответы
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]*list.Element{}
+	c.lru.Init()
+}
+
+// do - отдает свежий ответ из кэша или выполняет fetch, один раз на все одновременные запросы с key.
+// stale - устаревший ответ, который fetch может перепроверить по ETag.
+// fetch выполняется с ctx того, кто пришел первым. Его ошибка достается всем, кто его ждал, и не кэшируется,
+// кроме отмены или истечения ctx первого запроса: тогда ждавшие выполняют fetch заново со своим ctx
+func (c *Cache) do(ctx context.Context, key string, fetch func(stale *SearchResponse) (*SearchResponse, error)) (*SearchResponse, error) {
+	c.mu.Lock()
+	stale, fresh := c.lookup(key)
+	if fresh {
+		c.mu.Unlock()
+		return stale.clone(), nil
+	}
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if call.cancelled {
+			return c.do(ctx, key, fetch)
+		}
+		if call.err != nil {
+			return nil, call.err
+		}
+		return call.resp.clone(), nil
+	}
+	call := &cacheCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	resp, err := fetch(stale)
+	if err == nil && resp.notModified {
+		resp = stale
+	}
+
+	c.mu.Lock()
+	if err == nil {
+		c.store(key, resp)
+	}
+	delete(c.calls, key)
+	c.mu.Unlock()
+
+	call.resp, call.err = resp, err
+	call.cancelled = err != nil && ctx.Err() != nil
+	close(call.done)
+	if err != nil {
+		return nil, err
+	}
+	return resp.clone(), nil
+}
+
+// lookup - ответ по key и не истек ли он, вызывается под mu.
+// Устаревший ответ без ETag перепроверить нельзя, он сразу удаляется
+func (c *Cache) lookup(key string) (*SearchResponse, bool) {
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().Before(entry.expires) {
+		c.lru.MoveToFront(elem)
+		return entry.resp, true
+	}
+	if entry.resp.etag == "" {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.resp, false
+}
+
+// store - сохраняет ответ и вытесняет лишние, вызывается под mu
+func (c *Cache) store(key string, resp *SearchResponse) {
+	expires := time.Now().Add(c.TTL)
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		entry.resp, entry.expires = resp, expires
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, resp: resp, expires: expires})
+	for c.MaxEntries > 0 && c.lru.Len() > c.MaxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,6 +10,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -33,10 +35,27 @@ type User struct {
 type SearchResponse struct {
 	Users    []User
 	NextPage bool
+	// NextCursor - курсор следующей страницы, если внешняя система их поддерживает.
+	// Выдается только для запросов с сортировкой
+	NextCursor string
+
+	// etag - ETag ответа для перепроверки закэшированного ответа
+	etag string
+	// notModified - внешняя система ответила 304, данные нужно взять из кэша
+	notModified bool
+}
+
+// clone - копия, которую можно отдать наружу, не опасаясь изменений закэшированного ответа
+func (r *SearchResponse) clone() *SearchResponse {
+	c := *r
+	c.Users = append([]User(nil), r.Users...)
+	return &c
 }
 
 type SearchErrorResponse struct {
 	Error string
+	// Field - поле с ошибкой, если внешняя система его сообщает
+	Field string `json:",omitempty"`
 }
 
 const (
@@ -54,17 +73,104 @@ type SearchRequest struct {
 	OrderField string
 	// -1 по убыванию, 0 как встретилось, 1 по возрастанию
 	OrderBy int
+	// OrderFields - сортировка по нескольким полям по порядку, если задана - OrderField и OrderBy не учитываются
+	OrderFields []SortKey
+	// Match - как искать Query, по умолчанию MatchSubstring
+	Match MatchMode
+	// IgnoreCase - искать Query без учета регистра
+	IgnoreCase bool
+	// Gender - только пользователи этого пола, пустая строка - любые
+	Gender string
+	// MinAge, MaxAge - возраст включительно, 0 - без ограничения
+	MinAge int
+	MaxAge int
+	// Cursor - NextCursor предыдущей страницы, если задан - Offset не учитывается.
+	// Курсор действителен только для той же сортировки
+	Cursor string
+}
+
+// SortKey - одно поле многоключевой сортировки
+type SortKey struct {
+	Field string
+	// OrderBy - OrderByAsc или OrderByDesc
+	OrderBy int
+}
+
+// MatchMode - способ поиска Query в имени и описании
+type MatchMode string
+
+const (
+	// MatchSubstring - Query целиком как подстрока
+	MatchSubstring MatchMode = "substring"
+	// MatchWords - каждое слово Query в любом порядке
+	MatchWords MatchMode = "words"
+	// MatchPhrase - Query целиком, но только на границах слов
+	MatchPhrase MatchMode = "phrase"
+)
+
+// orderParam - OrderFields в формате "Age desc,Name asc"
+func orderParam(keys []SortKey) (string, error) {
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		switch key.OrderBy {
+		case OrderByAsc:
+			parts = append(parts, key.Field+" asc")
+		case OrderByDesc:
+			parts = append(parts, key.Field+" desc")
+		default:
+			return "", fmt.Errorf("order_by for %s must be asc or desc", key.Field)
+		}
+	}
+	return strings.Join(parts, ","), nil
 }
 
 type SearchClient struct {
 	// токен, по которому происходит авторизация на внешней системе, уходит туда через хедер
 	AccessToken string
+	// Auth - авторизация вместо AccessToken
+	Auth AuthProvider
 	// урл внешней системы, куда идти
 	URL string
+	// HTTPClient - клиент для запросов, если не задан - используется client с таймаутом в 1 секунду
+	HTTPClient *http.Client
+	// Transport - используется вместо http.DefaultTransport, если не задан HTTPClient
+	Transport http.RoundTripper
+	// Retry - повторы при таймаутах, сетевых ошибках и 5xx, если не задан - запрос выполняется один раз
+	Retry *RetryPolicy
+	// Breaker - перестает ходить во внешнюю систему после серии ошибок
+	Breaker *CircuitBreaker
+	// OnRetry - вызывается перед каждым повтором
+	OnRetry func(attempt int, delay time.Duration, err error)
+	// Cache - кэш ответов, если не задан - каждый FindUsers идет во внешнюю систему
+	Cache *Cache
+}
+
+// httpClient - клиент для запросов с учетом настроек HTTPClient и Transport
+func (srv *SearchClient) httpClient() *http.Client {
+	if srv.HTTPClient != nil {
+		return srv.HTTPClient
+	}
+	if srv.Transport != nil {
+		return &http.Client{Timeout: client.Timeout, Transport: srv.Transport}
+	}
+	return client
+}
+
+// auth - Auth или AccessToken, если Auth не задан
+func (srv *SearchClient) auth() AuthProvider {
+	if srv.Auth != nil {
+		return srv.Auth
+	}
+	return &StaticToken{Token: srv.AccessToken}
 }
 
 // FindUsers отправляет запрос во внешнюю систему, которая непосредственно ищет пользоваталей
 func (srv *SearchClient) FindUsers(req SearchRequest) (*SearchResponse, error) {
+	return srv.FindUsersContext(context.Background(), req)
+}
+
+// FindUsersContext - FindUsers, который можно отменить или ограничить по времени через ctx
+func (srv *SearchClient) FindUsersContext(ctx context.Context, req SearchRequest) (*SearchResponse, error) {
 
 	searcherParams := url.Values{}
 
@@ -77,6 +183,9 @@ func (srv *SearchClient) FindUsers(req SearchRequest) (*SearchResponse, error) {
 	if req.Offset < 0 {
 		return nil, fmt.Errorf("offset must be > 0")
 	}
+	if req.MinAge < 0 || req.MaxAge < 0 {
+		return nil, fmt.Errorf("age must be > 0")
+	}
 
 	//нужно для получения следующей записи, на основе которой мы скажем - можно показать переключатель следующей страницы или нет
 	req.Limit++
@@ -86,50 +195,188 @@ func (srv *SearchClient) FindUsers(req SearchRequest) (*SearchResponse, error) {
 	searcherParams.Add("query", req.Query)
 	searcherParams.Add("order_field", req.OrderField)
 	searcherParams.Add("order_by", strconv.Itoa(req.OrderBy))
+	if len(req.OrderFields) > 0 {
+		order, err := orderParam(req.OrderFields)
+		if err != nil {
+			return nil, err
+		}
+		searcherParams.Add("order", order)
+	}
+	if req.Match != "" {
+		searcherParams.Add("match", string(req.Match))
+	}
+	if req.IgnoreCase {
+		searcherParams.Add("ignore_case", "true")
+	}
+	if req.Gender != "" {
+		searcherParams.Add("gender", req.Gender)
+	}
+	if req.MinAge > 0 {
+		searcherParams.Add("min_age", strconv.Itoa(req.MinAge))
+	}
+	if req.MaxAge > 0 {
+		searcherParams.Add("max_age", strconv.Itoa(req.MaxAge))
+	}
+	if req.Cursor != "" {
+		searcherParams.Add("cursor", req.Cursor)
+	}
 
-	searcherReq, err := http.NewRequest("GET", srv.URL+"?"+searcherParams.Encode(), nil)
-	searcherReq.Header.Add("AccessToken", srv.AccessToken)
-	
-	resp, err := client.Do(searcherReq)
+	if srv.Cache == nil {
+		return srv.findAuthorized(ctx, req, searcherParams, "")
+	}
+	// searcherParams.Encode сортирует параметры, так что одинаковые запросы дают один ключ
+	key := srv.URL + "?" + searcherParams.Encode()
+	return srv.Cache.do(ctx, key, func(stale *SearchResponse) (*SearchResponse, error) {
+		etag := ""
+		if stale != nil {
+			etag = stale.etag
+		}
+		return srv.findAuthorized(ctx, req, searcherParams, etag)
+	})
+}
+
+// findAuthorized - после 401 обновляет авторизацию и повторяет запрос один раз
+func (srv *SearchClient) findAuthorized(ctx context.Context, req SearchRequest, searcherParams url.Values, etag string) (*SearchResponse, error) {
+	result, err := srv.findWithRetry(ctx, req, searcherParams, etag)
+	if !errors.Is(err, ErrUnauthorized) {
+		return result, err
+	}
+	if srv.auth().Refresh(ctx) != nil {
+		return nil, err
+	}
+	return srv.findWithRetry(ctx, req, searcherParams, etag)
+}
+
+// outcome - что запрос говорит о состоянии внешней системы
+type outcome int
+
+const (
+	// outcomeAnswered - система ответила как положено: данными, 304 или ошибкой клиента 4xx
+	outcomeAnswered outcome = iota
+	// outcomeFailed - таймаут, сетевая ошибка, 5xx или ответ, который не удалось разобрать
+	outcomeFailed
+	// outcomeNotSent - запрос не был отправлен, о системе ничего не известно
+	outcomeNotSent
+)
+
+// findWithRetry - повторяет запрос по настройкам Retry и учитывает его результат в Breaker
+func (srv *SearchClient) findWithRetry(ctx context.Context, req SearchRequest, searcherParams url.Values, etag string) (*SearchResponse, error) {
+	for attempt := 1; ; attempt++ {
+		if srv.Breaker != nil {
+			if err := srv.Breaker.Allow(); err != nil {
+				return nil, err
+			}
+		}
+
+		result, outcome, err := srv.findOnce(ctx, req, searcherParams, etag)
+		if srv.Breaker != nil {
+			if outcome == outcomeNotSent || err != nil && ctx.Err() == context.Canceled {
+				// запрос отменил вызывающий - сервер тут ни при чем
+				srv.Breaker.Release()
+			} else {
+				// ошибки клиента (токен, параметры) не говорят о том, что сервер сломан,
+				// а таймауты говорят, даже если истек дедлайн всего ctx
+				srv.Breaker.Record(outcome == outcomeAnswered)
+			}
+		}
+		// после дедлайна всего ctx повторять бесполезно
+		retryable := outcome == outcomeFailed && ctx.Err() == nil
+		if err == nil || !retryable || srv.Retry == nil || attempt >= srv.Retry.MaxAttempts {
+			return result, err
+		}
+
+		delay := srv.Retry.delay(attempt)
+		if srv.OnRetry != nil {
+			srv.OnRetry(attempt, delay, err)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// findOnce - один запрос к внешней системе, outcomeFailed - ошибка временная и запрос можно повторить.
+// Если задан etag и ответ не изменился, возвращается SearchResponse с notModified
+func (srv *SearchClient) findOnce(ctx context.Context, req SearchRequest, searcherParams url.Values, etag string) (*SearchResponse, outcome, error) {
+	searcherReq, err := http.NewRequestWithContext(ctx, "GET", srv.URL+"?"+searcherParams.Encode(), nil)
 	if err != nil {
+		return nil, outcomeNotSent, fmt.Errorf("cant create request: %s", err)
+	}
+	if err := srv.auth().Apply(ctx, searcherReq); err != nil {
+		return nil, outcomeNotSent, fmt.Errorf("cant authorize request: %s", err)
+	}
+	if etag != "" {
+		searcherReq.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := srv.httpClient().Do(searcherReq)
+	if err != nil {
+		if ctx.Err() == context.Canceled {
+			return nil, outcomeNotSent, ctx.Err()
+		}
 		if err, ok := err.(net.Error); ok && err.Timeout() {
-			return nil, fmt.Errorf("timeout for %s", searcherParams.Encode())
+			return nil, outcomeFailed, &TimeoutError{Params: searcherParams, Err: err}
 		}
-		return nil, fmt.Errorf("unknown error %s", err)
+		return nil, outcomeFailed, fmt.Errorf("unknown error %s", err)
 	}
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, outcomeFailed, fmt.Errorf("cant read response: %s", err)
+	}
 
 	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if etag != "" {
+			return &SearchResponse{notModified: true}, outcomeAnswered, nil
+		}
 	case http.StatusUnauthorized:
-		return nil, fmt.Errorf("Bad AccessToken")
-	case http.StatusInternalServerError:
-		return nil, fmt.Errorf("SearchServer fatal error")
+		return nil, outcomeAnswered, ErrUnauthorized
 	case http.StatusBadRequest:
 		errResp := SearchErrorResponse{}
 		err = json.Unmarshal(body, &errResp)
 		if err != nil {
-			return nil, fmt.Errorf("cant unpack error json: %s", err)
+			return nil, outcomeAnswered, fmt.Errorf("cant unpack error json: %s", err)
 		}
 		if errResp.Error == "ErrorBadOrderField" {
-			return nil, fmt.Errorf("OrderFeld %s invalid", req.OrderField)
+			field := errResp.Field
+			if field == "" {
+				field = req.OrderField
+			}
+			return nil, outcomeAnswered, &OrderFieldError{Field: field}
+		}
+		if errResp.Error == "ErrorBadCursor" {
+			return nil, outcomeAnswered, ErrBadCursor
 		}
-		return nil, fmt.Errorf("unknown bad request error: %s", errResp.Error)
+		return nil, outcomeAnswered, fmt.Errorf("unknown bad request error: %s", errResp.Error)
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, outcomeFailed, &ServerError{StatusCode: resp.StatusCode}
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, outcomeAnswered, &ClientError{StatusCode: resp.StatusCode}
 	}
 
 	data := []User{}
 	err = json.Unmarshal(body, &data)
 	if err != nil {
-		return nil, fmt.Errorf("cant unpack result json: %s", err)
+		// сервер ответил успехом, но отдал мусор - он неисправен так же, как при 5xx
+		return nil, outcomeFailed, fmt.Errorf("cant unpack result json: %s", err)
 	}
 
-	result := SearchResponse{}
+	result := SearchResponse{etag: resp.Header.Get("ETag")}
 	if len(data) == req.Limit {
 		result.NextPage = true
 		result.Users = data[0 : len(data)-1]
+		// курсор указывает на последнего отданного пользователя - лишнего, который и начинает следующую страницу
+		result.NextCursor = resp.Header.Get("X-Cursor")
 	} else {
 		result.Users = data[0:len(data)]
 	}
 
-	return &result, err
+	return &result, outcomeAnswered, nil
 }
@@ -1,18 +1,25 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/dgkrivenko/cursera-go-pt1/lesson-4/searchserver"
 )
 
 type Row struct {
@@ -359,3 +366,822 @@ func TestFindStatusCode(t *testing.T) {
 
 	c.AccessToken = "test"
 }
+
+// roundTripFunc - транспорт-заглушка для тестов без сервера
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestFindUsersContext(t *testing.T) {
+	tsSlow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer tsSlow.Close()
+	clientSlow := SearchClient{
+		AccessToken: "test",
+		URL:         tsSlow.URL,
+		HTTPClient:  &http.Client{},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := clientSlow.FindUsersContext(ctx, SearchRequest{})
+	if err == nil || !strings.HasPrefix(err.Error(), "timeout for") {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = clientSlow.FindUsersContext(ctx, SearchRequest{})
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestFindTransport(t *testing.T) {
+	var gotToken, gotQuery string
+	clientStub := SearchClient{
+		AccessToken: "stub",
+		URL:         "http://search.local/",
+		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			gotToken = r.Header.Get("AccessToken")
+			gotQuery = r.URL.RawQuery
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(strings.NewReader(`[{"Id":1,"Name":"Stub"},{"Id":2,"Name":"Next"}]`)),
+				Header:     http.Header{},
+			}, nil
+		}),
+	}
+
+	resp, err := clientStub.FindUsers(SearchRequest{Limit: 1, Query: "x"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Users) != 1 || resp.Users[0].Name != "Stub" || !resp.NextPage {
+		t.Errorf("bad response: %+v", resp)
+	}
+	if gotToken != "stub" || gotQuery != "limit=2&offset=0&order_by=0&order_field=&query=x" {
+		t.Errorf("bad request: %s %s", gotToken, gotQuery)
+	}
+}
+
+// pagingServer - отдает total пользователей с учетом limit и offset, на offset failAt отвечает 500
+func pagingServer(total, failAt int, requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		if offset == failAt {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		data := []User{}
+		for id := offset; id < total && id < offset+limit; id++ {
+			data = append(data, User{Id: id})
+		}
+		body, _ := json.Marshal(data)
+		w.Write(body)
+	}))
+}
+
+func TestIterate(t *testing.T) {
+	requests := 0
+	ts := pagingServer(60, -1, &requests)
+	defer ts.Close()
+	c := SearchClient{AccessToken: "test", URL: ts.URL}
+
+	users, err := c.FindAllUsers(context.Background(), SearchRequest{}, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(users) != 60 || requests != 3 {
+		t.Fatalf("expected 60 users in 3 requests, got %d in %d", len(users), requests)
+	}
+	for i, u := range users {
+		if u.Id != i {
+			t.Fatalf("bad order at %d: %+v", i, u)
+		}
+	}
+
+	users, err = c.FindAllUsers(context.Background(), SearchRequest{Limit: 10, Offset: 5}, 12)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(users) != 12 || users[0].Id != 5 || users[11].Id != 16 {
+		t.Errorf("bad users with max: %+v", users)
+	}
+}
+
+func TestIterateLastPageLimit(t *testing.T) {
+	var limits []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limits = append(limits, r.URL.Query().Get("limit"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		data := []User{}
+		for id := offset; id < offset+limit; id++ {
+			data = append(data, User{Id: id})
+		}
+		body, _ := json.Marshal(data)
+		w.Write(body)
+	}))
+	defer ts.Close()
+	c := SearchClient{AccessToken: "test", URL: ts.URL}
+
+	users, err := c.FindAllUsers(context.Background(), SearchRequest{Limit: 10, Offset: 5}, 12)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(users) != 12 || !reflect.DeepEqual(limits, []string{"11", "3"}) {
+		t.Errorf("expected 12 users with limits [11 3], got %d with %v", len(users), limits)
+	}
+}
+
+func TestIterateCancel(t *testing.T) {
+	requests := 0
+	ts := pagingServer(500, -1, &requests)
+	defer ts.Close()
+	c := SearchClient{AccessToken: "test", URL: ts.URL}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	it := c.Iterate(ctx, SearchRequest{}, 0)
+	count := 0
+	for it.Next() {
+		count++
+		if count == 1 {
+			cancel()
+		}
+	}
+	if count >= 500 || !errors.Is(it.Err(), context.Canceled) {
+		t.Errorf("expected context.Canceled after part of users, got %d users and %v", count, it.Err())
+	}
+
+	it = c.Iterate(context.Background(), SearchRequest{}, 0)
+	it.Next()
+	it.Close()
+	if it.Next() || it.Err() != nil {
+		t.Errorf("expected no users and no error after Close, got %v", it.Err())
+	}
+}
+
+func TestIterateError(t *testing.T) {
+	requests := 0
+	ts := pagingServer(60, 25, &requests)
+	defer ts.Close()
+	c := SearchClient{AccessToken: "test", URL: ts.URL}
+
+	it := c.Iterate(context.Background(), SearchRequest{}, 0)
+	count := 0
+	for it.Next() {
+		count++
+	}
+	if count != 25 || it.Err() == nil || it.Err().Error() != "SearchServer fatal error" {
+		t.Errorf("expected error after 25 users, got %d users and %v", count, it.Err())
+	}
+	if it.Next() {
+		t.Errorf("Next after error must return false")
+	}
+}
+
+func TestFindRetry(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`[{"Id":1}]`))
+	}))
+	defer ts.Close()
+
+	var delays []time.Duration
+	c := SearchClient{
+		AccessToken: "test",
+		URL:         ts.URL,
+		Retry:       &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, Jitter: 0.5},
+		OnRetry: func(attempt int, delay time.Duration, err error) {
+			delays = append(delays, delay)
+		},
+	}
+	resp, err := c.FindUsers(SearchRequest{Limit: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Users) != 1 || requests != 3 || len(delays) != 2 {
+		t.Errorf("expected success on 3rd attempt, got %d requests, delays %v", requests, delays)
+	}
+	if delays[0] > time.Millisecond || delays[1] > 2*time.Millisecond || delays[1] < time.Millisecond {
+		t.Errorf("bad backoff delays: %v", delays)
+	}
+
+	// ошибки клиента не повторяются
+	requests = 0
+	tsAuth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer tsAuth.Close()
+	c.URL = tsAuth.URL
+	_, err = c.FindUsers(SearchRequest{})
+	if err == nil || requests != 1 {
+		t.Errorf("expected single request for 401, got %d", requests)
+	}
+}
+
+func TestFindClientError(t *testing.T) {
+	for _, status := range []int{http.StatusForbidden, http.StatusNotFound} {
+		requests := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(status)
+		}))
+		breaker := NewCircuitBreaker(1, time.Minute)
+		c := SearchClient{
+			AccessToken: "test",
+			URL:         ts.URL,
+			Retry:       &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+			Breaker:     breaker,
+		}
+
+		// 4xx - ответ на запрос, а не сбой: не повторяется и не открывает breaker
+		_, err := c.FindUsers(SearchRequest{})
+		clientErr := &ClientError{}
+		if !errors.As(err, &clientErr) || clientErr.StatusCode != status {
+			t.Errorf("[%d] expected ClientError, got %v", status, err)
+		}
+		if requests != 1 || breaker.State() != BreakerClosed {
+			t.Errorf("[%d] expected single request and closed breaker, got %d requests, %s breaker", status, requests, breaker.State())
+		}
+		ts.Close()
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	expected := []time.Duration{10, 20, 40, 50, 50}
+	for i, e := range expected {
+		if d := p.delay(i + 1); d != e*time.Millisecond {
+			t.Errorf("attempt %d: expected %v, got %v", i+1, e*time.Millisecond, d)
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	requests := 0
+	fail := true
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer ts.Close()
+
+	var states []string
+	breaker := NewCircuitBreaker(2, 50*time.Millisecond)
+	breaker.OnStateChange = func(from, to BreakerState) {
+		states = append(states, from.String()+"->"+to.String())
+	}
+	c := SearchClient{AccessToken: "test", URL: ts.URL, Breaker: breaker}
+
+	c.FindUsers(SearchRequest{})
+	c.FindUsers(SearchRequest{})
+	if breaker.State() != BreakerOpen {
+		t.Fatalf("expected open breaker, got %s", breaker.State())
+	}
+	_, err := c.FindUsers(SearchRequest{})
+	if err != ErrCircuitOpen || requests != 2 {
+		t.Errorf("expected fail fast, got %v after %d requests", err, requests)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	fail = false
+	if _, err := c.FindUsers(SearchRequest{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := []string{"closed->open", "open->half-open", "half-open->closed"}
+	if !reflect.DeepEqual(states, expected) {
+		t.Errorf("bad state changes: %v", states)
+	}
+
+	// неудачный пробный запрос снова открывает breaker
+	fail = true
+	c.FindUsers(SearchRequest{})
+	c.FindUsers(SearchRequest{})
+	time.Sleep(60 * time.Millisecond)
+	c.FindUsers(SearchRequest{})
+	if breaker.State() != BreakerOpen {
+		t.Errorf("expected open breaker after failed probe, got %s", breaker.State())
+	}
+}
+
+func TestCircuitBreakerContext(t *testing.T) {
+	var mode atomic.Value
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch mode.Load() {
+		case "hang":
+			<-r.Context().Done()
+		case "garbage":
+			w.Write([]byte(`[}`))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer ts.Close()
+
+	breaker := NewCircuitBreaker(2, 50*time.Millisecond)
+	c := SearchClient{AccessToken: "test", URL: ts.URL, HTTPClient: &http.Client{}, Breaker: breaker}
+	findWithin := func(timeout time.Duration) error {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		_, err := c.FindUsersContext(ctx, SearchRequest{})
+		return err
+	}
+
+	// дедлайн вызывающего на зависшем сервере - это отказ сервера
+	mode.Store("hang")
+	findWithin(20 * time.Millisecond)
+	findWithin(20 * time.Millisecond)
+	if breaker.State() != BreakerOpen {
+		t.Fatalf("expected open breaker after deadlines, got %s", breaker.State())
+	}
+
+	// отмененный пробный запрос не закрывает breaker и не занимает место пробного
+	time.Sleep(60 * time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := c.FindUsersContext(ctx, SearchRequest{}); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if breaker.State() != BreakerHalfOpen {
+		t.Fatalf("expected half-open breaker after cancelled probe, got %s", breaker.State())
+	}
+	mode.Store("ok")
+	if err := findWithin(time.Second); err != nil || breaker.State() != BreakerClosed {
+		t.Fatalf("expected closed breaker after probe, got %s and %v", breaker.State(), err)
+	}
+
+	// 200 с мусором вместо json - тоже отказ
+	mode.Store("garbage")
+	findWithin(time.Second)
+	findWithin(time.Second)
+	if breaker.State() != BreakerOpen {
+		t.Errorf("expected open breaker after undecodable responses, got %s", breaker.State())
+	}
+}
+
+func TestFindTypedErrors(t *testing.T) {
+	_, err := c.FindUsers(SearchRequest{OrderField: "werwr", OrderBy: 1})
+	orderErr := &OrderFieldError{}
+	if !errors.Is(err, ErrBadOrderField) || !errors.As(err, &orderErr) || orderErr.Field != "werwr" {
+		t.Errorf("expected ErrBadOrderField, got %v", err)
+	}
+	if err.Error() != "OrderFeld werwr invalid" {
+		t.Errorf("bad message: %v", err)
+	}
+
+	badToken := SearchClient{AccessToken: "bad", URL: ts.URL}
+	_, err = badToken.FindUsers(SearchRequest{})
+	if !errors.Is(err, ErrUnauthorized) || err.Error() != "Bad AccessToken" {
+		t.Errorf("expected ErrUnauthorized, got %v", err)
+	}
+
+	tsInternal := httptest.NewServer(http.HandlerFunc(SearchServerTimeoutInternalError))
+	defer tsInternal.Close()
+	_, err = (&SearchClient{URL: tsInternal.URL}).FindUsers(SearchRequest{})
+	serverErr := &ServerError{}
+	if !errors.As(err, &serverErr) || serverErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected ServerError, got %v", err)
+	}
+
+	tsTimeout := httptest.NewServer(http.HandlerFunc(SearchServerTimeout))
+	defer tsTimeout.Close()
+	_, err = (&SearchClient{URL: tsTimeout.URL}).FindUsers(SearchRequest{Limit: 3, Query: "q"})
+	timeoutErr := &TimeoutError{}
+	if !errors.As(err, &timeoutErr) || timeoutErr.Params.Get("limit") != "4" || timeoutErr.Params.Get("query") != "q" {
+		t.Errorf("expected TimeoutError, got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "timeout for ") {
+		t.Errorf("bad message: %v", err)
+	}
+}
+
+func TestFindSearchServer(t *testing.T) {
+	srv, err := searchserver.NewFromXML("dataset.xml", searchserver.NewStaticTokens("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	c := SearchClient{AccessToken: "test", URL: ts.URL}
+	users, err := c.FindAllUsers(context.Background(), SearchRequest{OrderField: "Age", OrderBy: OrderByAsc}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 35 {
+		t.Fatalf("expected 35 users, got %d", len(users))
+	}
+	for i := 1; i < len(users); i++ {
+		if users[i-1].Age > users[i].Age {
+			t.Fatalf("users not sorted by age: %d before %d", users[i-1].Age, users[i].Age)
+		}
+	}
+
+	_, err = c.FindUsers(SearchRequest{OrderField: "About"})
+	if !errors.Is(err, ErrBadOrderField) {
+		t.Errorf("expected ErrBadOrderField, got %v", err)
+	}
+}
+
+func TestFindOrderFilters(t *testing.T) {
+	srv, err := searchserver.NewFromXML("dataset.xml", searchserver.NewStaticTokens("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	c := SearchClient{AccessToken: "test", URL: ts.URL}
+
+	users, err := c.FindAllUsers(context.Background(), SearchRequest{
+		OrderFields: []SortKey{{"Age", OrderByDesc}, {"Name", OrderByAsc}},
+		MinAge:      25,
+		MaxAge:      30,
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) == 0 {
+		t.Fatal("expected users")
+	}
+	for i, user := range users {
+		if user.Age < 25 || user.Age > 30 {
+			t.Errorf("age %d out of range", user.Age)
+		}
+		if i > 0 {
+			prev := users[i-1]
+			if prev.Age < user.Age || (prev.Age == user.Age && prev.Name > user.Name) {
+				t.Errorf("bad order: %+v before %+v", prev, user)
+			}
+		}
+	}
+
+	resp, err := c.FindUsers(SearchRequest{Limit: 5, Query: "BOYD wolf", IgnoreCase: true, Match: MatchWords, Gender: "male"})
+	if err != nil || len(resp.Users) != 1 || resp.Users[0].Id != 0 {
+		t.Errorf("unexpected result %+v, %v", resp, err)
+	}
+
+	_, err = c.FindUsers(SearchRequest{OrderFields: []SortKey{{"Age", OrderByDesc}, {"About", OrderByAsc}}})
+	orderErr := &OrderFieldError{}
+	if !errors.Is(err, ErrBadOrderField) || !errors.As(err, &orderErr) || orderErr.Field != "About" {
+		t.Errorf("expected OrderFieldError, got %v", err)
+	}
+
+	_, err = c.FindUsers(SearchRequest{OrderFields: []SortKey{{"Age", OrderByAsIs}}})
+	if err == nil {
+		t.Error("expected error for OrderByAsIs in OrderFields")
+	}
+}
+
+func TestFindCursor(t *testing.T) {
+	srv, err := searchserver.NewFromXML("dataset.xml", searchserver.NewStaticTokens("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursors := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("cursor") != "" {
+			cursors++
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	c := SearchClient{AccessToken: "test", URL: ts.URL}
+
+	req := SearchRequest{Limit: 10, OrderFields: []SortKey{{"Age", OrderByDesc}}}
+	resp, err := c.FindUsers(req)
+	if err != nil || !resp.NextPage || resp.NextCursor == "" {
+		t.Fatalf("expected next cursor, got %+v, %v", resp, err)
+	}
+	req.Cursor = resp.NextCursor
+	next, err := c.FindUsers(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := resp.Users[len(resp.Users)-1]
+	for _, user := range next.Users {
+		if user.Id == last.Id || user.Age > last.Age {
+			t.Errorf("user %+v repeats previous page", user)
+		}
+	}
+
+	users, err := c.FindAllUsers(context.Background(), SearchRequest{Limit: 10, OrderFields: []SortKey{{"Name", OrderByAsc}}}, 0)
+	if err != nil || len(users) != 35 {
+		t.Fatalf("expected 35 users, got %d, %v", len(users), err)
+	}
+	if cursors != 4 {
+		t.Errorf("expected 4 requests with cursor, got %d", cursors)
+	}
+
+	req.OrderFields = []SortKey{{"Name", OrderByAsc}}
+	_, err = c.FindUsers(req)
+	if err != ErrBadCursor {
+		t.Errorf("expected ErrBadCursor, got %v", err)
+	}
+}
+
+// cachedServer - searchserver, который считает запросы и ответы 304
+func cachedServer(t *testing.T, delay time.Duration, requests, notModified *int32) *httptest.Server {
+	srv, err := searchserver.NewFromXML("dataset.xml", searchserver.NewStaticTokens("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		time.Sleep(delay)
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, r)
+		if rec.Code == http.StatusNotModified {
+			atomic.AddInt32(notModified, 1)
+		}
+		for key, values := range rec.Header() {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+	}))
+}
+
+func TestFindCache(t *testing.T) {
+	var requests, notModified int32
+	ts := cachedServer(t, 0, &requests, &notModified)
+	defer ts.Close()
+	c := SearchClient{AccessToken: "test", URL: ts.URL, Cache: NewCache(50*time.Millisecond, 2)}
+
+	first, err := c.FindUsers(SearchRequest{Limit: 5, Query: "Boyd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Users[0].Name = "changed"
+	second, err := c.FindUsers(SearchRequest{Limit: 5, Query: "Boyd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 1 || second.Users[0].Name != "Boyd Wolf" {
+		t.Errorf("expected cached copy after 1 request, got %d requests, %+v", requests, second.Users)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	third, err := c.FindUsers(SearchRequest{Limit: 5, Query: "Boyd"})
+	if err != nil || len(third.Users) != 1 || third.Users[0].Name != "Boyd Wolf" {
+		t.Fatalf("unexpected result %+v, %v", third, err)
+	}
+	if requests != 2 || notModified != 1 {
+		t.Errorf("expected revalidation with 304, got %d requests, %d not modified", requests, notModified)
+	}
+
+	c.FindUsers(SearchRequest{Limit: 5, Query: "Wolf"})
+	c.FindUsers(SearchRequest{Limit: 5, Query: "Hilda"})
+	if c.Cache.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", c.Cache.Len())
+	}
+	c.FindUsers(SearchRequest{Limit: 5, Query: "Boyd"})
+	if requests != 5 {
+		t.Errorf("expected evicted entry to be requested again, got %d requests", requests)
+	}
+}
+
+func TestFindCacheSingleFlight(t *testing.T) {
+	var requests, notModified int32
+	ts := cachedServer(t, 50*time.Millisecond, &requests, &notModified)
+	defer ts.Close()
+	c := SearchClient{AccessToken: "test", URL: ts.URL, Cache: NewCache(time.Minute, 0)}
+
+	wg := sync.WaitGroup{}
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := c.FindUsers(SearchRequest{Limit: 3, OrderField: "Age", OrderBy: OrderByAsc})
+			if err == nil && len(resp.Users) != 3 {
+				err = fmt.Errorf("expected 3 users, got %d", len(resp.Users))
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected 1 request, got %d", requests)
+	}
+}
+
+func TestFindCacheLeaderCancel(t *testing.T) {
+	var requests, notModified int32
+	ts := cachedServer(t, 50*time.Millisecond, &requests, &notModified)
+	defer ts.Close()
+	c := SearchClient{AccessToken: "test", URL: ts.URL, Cache: NewCache(time.Minute, 0)}
+	req := SearchRequest{Limit: 3, OrderField: "Age", OrderBy: OrderByAsc}
+
+	// первый запрос уходит во внешнюю систему и отменяется, ждущий его запрос должен получить ответ
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := c.FindUsersContext(ctx, req)
+		leaderErr <- err
+	}()
+	time.Sleep(5 * time.Millisecond)
+	resp, err := c.FindUsers(req)
+	if err != nil || len(resp.Users) != 3 {
+		t.Errorf("expected waiter to fetch by itself, got %+v, %v", resp, err)
+	}
+	if err := <-leaderErr; err == nil {
+		t.Error("expected error for cancelled leader")
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 requests, got %d", requests)
+	}
+}
+
+func TestFindAuthProviders(t *testing.T) {
+	tokens := searchserver.NewStaticTokens("first")
+	srv, err := searchserver.NewFromXML("dataset.xml", tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	fetched := []string{"first", "second"}
+	fetches := 0
+	bearer := &BearerToken{
+		Fetch: func(ctx context.Context) (string, time.Time, error) {
+			token := fetched[fetches%len(fetched)]
+			fetches++
+			return token, time.Now().Add(time.Hour), nil
+		},
+		Leeway: time.Minute,
+	}
+	c := SearchClient{URL: ts.URL, Auth: bearer}
+	if _, err := c.FindUsers(SearchRequest{Limit: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.FindUsers(SearchRequest{Limit: 1}); err != nil || fetches != 1 {
+		t.Fatalf("expected cached token, got %d fetches, %v", fetches, err)
+	}
+
+	// токен сменился на сервере - клиент обновляет свой после 401 и повторяет запрос
+	tokens.Revoke("first")
+	tokens.Add("second")
+	if _, err := c.FindUsers(SearchRequest{Limit: 1}); err != nil || fetches != 2 {
+		t.Fatalf("expected refresh after 401, got %d fetches, %v", fetches, err)
+	}
+
+	// обновление не помогло - второй 401 возвращается как есть
+	tokens.Revoke("second")
+	if _, err := c.FindUsers(SearchRequest{Limit: 1}); err != ErrUnauthorized || fetches != 3 {
+		t.Errorf("expected ErrUnauthorized after one refresh, got %d fetches, %v", fetches, err)
+	}
+
+	// истекающий токен обновляется до запроса
+	bearer.expiry = time.Now().Add(30 * time.Second)
+	tokens.Add("second")
+	if _, err := c.FindUsers(SearchRequest{Limit: 1}); err != nil || fetches != 4 {
+		t.Errorf("expected refresh before expiry, got %d fetches, %v", fetches, err)
+	}
+}
+
+func TestBearerTokenConcurrentRefresh(t *testing.T) {
+	var fetches int32
+	release := make(chan struct{})
+	bearer := &BearerToken{
+		Fetch: func(ctx context.Context) (string, time.Time, error) {
+			atomic.AddInt32(&fetches, 1)
+			<-release
+			return "fresh", time.Time{}, nil
+		},
+	}
+	bearer.token = "old"
+
+	// одновременные 401 обновляют токен один раз
+	wg := sync.WaitGroup{}
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- bearer.Refresh(context.Background())
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	// пока идет Fetch, запросы с текущим токеном не блокируются
+	r, _ := http.NewRequest(http.MethodGet, "http://search.local/", nil)
+	if err := bearer.Apply(context.Background(), r); err != nil || r.Header.Get("Authorization") != "Bearer old" {
+		t.Errorf("expected old token during refresh, got %q, %v", r.Header.Get("Authorization"), err)
+	}
+
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("expected 1 fetch, got %d", fetches)
+	}
+	if err := bearer.Apply(context.Background(), r); err != nil || r.Header.Get("Authorization") != "Bearer fresh" {
+		t.Errorf("expected fresh token, got %q, %v", r.Header.Get("Authorization"), err)
+	}
+}
+
+func TestBearerTokenLeaderCancel(t *testing.T) {
+	var fetches int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	bearer := &BearerToken{
+		Fetch: func(ctx context.Context) (string, time.Time, error) {
+			if atomic.AddInt32(&fetches, 1) == 1 {
+				close(started)
+			}
+			<-release
+			return "fresh", time.Time{}, ctx.Err()
+		},
+	}
+
+	// первый вызов начинает Fetch и уходит по отмене
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		leader <- bearer.Refresh(ctx)
+	}()
+	<-started
+	waiter := make(chan error, 1)
+	go func() {
+		waiter <- bearer.Refresh(context.Background())
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	if err := <-leader; err != context.Canceled {
+		t.Errorf("expected context.Canceled for leader, got %v", err)
+	}
+
+	// Fetch не отменяется вместе с ним, и ожидающий получает токен
+	close(release)
+	if err := <-waiter; err != nil || atomic.LoadInt32(&fetches) != 1 {
+		t.Errorf("expected token for waiter from 1 fetch, got %d fetches, %v", fetches, err)
+	}
+	r, _ := http.NewRequest(http.MethodGet, "http://search.local/", nil)
+	if err := bearer.Apply(context.Background(), r); err != nil || r.Header.Get("Authorization") != "Bearer fresh" {
+		t.Errorf("expected fresh token, got %q, %v", r.Header.Get("Authorization"), err)
+	}
+}
+
+func TestFindHMACSigner(t *testing.T) {
+	signer := &HMACSigner{
+		KeyID:  "dashboards",
+		Secret: []byte("secret"),
+		Now:    func() time.Time { return time.Unix(1600000000, 0) },
+	}
+	var got http.Header
+	var uri string
+	c := SearchClient{
+		URL:  "http://search.local/",
+		Auth: signer,
+		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			got, uri = r.Header, r.URL.RequestURI()
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(strings.NewReader(`[]`)),
+				Header:     http.Header{},
+			}, nil
+		}),
+	}
+	if _, err := c.FindUsers(SearchRequest{Limit: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Get("X-Signature-Key") != "dashboards" || got.Get("X-Signature-Timestamp") != "1600000000" ||
+		got.Get("X-Signature") != "82c00a9af783cbaf3bb0c9f57ada19854e2cf451a312e679b2666eaca8499df3" ||
+		uri != "/?limit=2&offset=0&order_by=0&order_field=&query=" || got.Get("AccessToken") != "" {
+		t.Errorf("bad signature headers: %v", got)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+var (
+	// ErrUnauthorized - внешняя система не приняла AccessToken
+	ErrUnauthorized = errors.New("Bad AccessToken")
+	// ErrBadOrderField - сортировка по неизвестному полю, конкретное поле лежит в *OrderFieldError
+	ErrBadOrderField = errors.New(ErrorBadOrderField)
+	// ErrBadCursor - внешняя система не приняла курсор: он поврежден или выдан для другой сортировки
+	ErrBadCursor = errors.New("bad cursor")
+)
+
+// OrderFieldError - внешняя система не умеет сортировать по Field
+type OrderFieldError struct {
+	Field string
+}
+
+func (e *OrderFieldError) Error() string {
+	return fmt.Sprintf("OrderFeld %s invalid", e.Field)
+}
+
+func (e *OrderFieldError) Is(target error) bool {
+	return target == ErrBadOrderField
+}
+
+// TimeoutError - внешняя система не ответила вовремя
+type TimeoutError struct {
+	// Params - параметры запроса в том виде, в котором они ушли во внешнюю систему
+	Params url.Values
+	Err    error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timeout for %s", e.Params.Encode())
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout - для совместимости с net.Error
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+// ServerError - внешняя система ответила 5xx
+type ServerError struct {
+	StatusCode int
+}
+
+func (e *ServerError) Error() string {
+	return "SearchServer fatal error"
+}
+
+// ClientError - внешняя система отклонила запрос 4xx, для которого нет отдельной ошибки.
+// Запрос не изменится от повтора, поэтому он не повторяется и не считается сбоем для breaker
+type ClientError struct {
+	StatusCode int
+}
+
+func (e *ClientError) Error() string {
+	return fmt.Sprintf("SearchServer rejected request: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+)
+
+// maxPageLimit - больше FindUsers за один запрос не отдает
+const maxPageLimit = 25
+
+// UserIterator - обход всех найденных пользователей по страницам.
+// Следующая страница запрашивается заранее, пока обрабатывается текущая
+type UserIterator struct {
+	cancel context.CancelFunc
+	pages  <-chan pageResult
+	users  []User
+	user   User
+	count  int
+	max    int
+	err    error
+	closed bool
+	// stopErr - почему предзагрузка остановилась, не отдав все страницы.
+	// Пишется до закрытия pages, так что после закрытия его можно читать
+	stopErr error
+}
+
+type pageResult struct {
+	users []User
+	err   error
+}
+
+// Iterate - начинает обход с req.Offset страницами по req.Limit (по умолчанию и максимум - 25).
+// Если внешняя система выдает курсоры, следующие страницы запрашиваются по ним, иначе - по offset.
+// max ограничивает общее число пользователей, max <= 0 - без ограничения.
+// Итератор нужно закрыть через Close, если он не был пройден до конца
+func (srv *SearchClient) Iterate(ctx context.Context, req SearchRequest, max int) *UserIterator {
+	if req.Limit <= 0 || req.Limit > maxPageLimit {
+		req.Limit = maxPageLimit
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	// буфер на одну страницу - это и есть предзагрузка
+	pages := make(chan pageResult, 1)
+	it := &UserIterator{
+		cancel: cancel,
+		pages:  pages,
+		max:    max,
+	}
+	go srv.fetchPages(ctx, req, max, pages, &it.stopErr)
+	return it
+}
+
+// fetchPages - запрашивает страницы, пока есть NextPage, не набрано max или не случилась ошибка.
+// Если ctx отменен раньше, его ошибка записывается в stopErr
+func (srv *SearchClient) fetchPages(ctx context.Context, req SearchRequest, max int, pages chan<- pageResult, stopErr *error) {
+	defer close(pages)
+	fetched := 0
+	for {
+		// последняя страница - ровно столько, сколько не хватает до max
+		if max > 0 && max-fetched < req.Limit {
+			req.Limit = max - fetched
+		}
+		resp, err := srv.FindUsersContext(ctx, req)
+		result := pageResult{err: err}
+		if err == nil {
+			result.users = resp.Users
+		}
+		select {
+		case pages <- result:
+		case <-ctx.Done():
+			*stopErr = ctx.Err()
+			return
+		}
+		if err != nil || !resp.NextPage || len(resp.Users) == 0 {
+			return
+		}
+		fetched += len(resp.Users)
+		if max > 0 && fetched >= max {
+			return
+		}
+		if resp.NextCursor != "" {
+			req.Cursor = resp.NextCursor
+		} else {
+			req.Offset += len(resp.Users)
+		}
+	}
+}
+
+// Next - переходит к следующему пользователю, false - пользователи кончились или произошла ошибка
+func (it *UserIterator) Next() bool {
+	if it.closed || it.err != nil || (it.max > 0 && it.count >= it.max) {
+		it.Close()
+		return false
+	}
+	for len(it.users) == 0 {
+		page, ok := <-it.pages
+		if !ok {
+			// без stopErr обход был бы молча обрезан
+			it.err = it.stopErr
+			it.Close()
+			return false
+		}
+		if page.err != nil {
+			it.err = page.err
+			it.Close()
+			return false
+		}
+		it.users = page.users
+	}
+	it.user = it.users[0]
+	it.users = it.users[1:]
+	it.count++
+	return true
+}
+
+// User - текущий пользователь
+func (it *UserIterator) User() User {
+	return it.user
+}
+
+// Err - ошибка, из-за которой обход остановился
+func (it *UserIterator) Err() error {
+	return it.err
+}
+
+// Close - останавливает предзагрузку страниц, после него Next возвращает false без ошибки
+func (it *UserIterator) Close() {
+	it.closed = true
+	it.cancel()
+}
+
+// FindAllUsers - собирает до max пользователей со всех страниц
+func (srv *SearchClient) FindAllUsers(ctx context.Context, req SearchRequest, max int) ([]User, error) {
+	it := srv.Iterate(ctx, req, max)
+	defer it.Close()
+	var users []User
+	for it.Next() {
+		users = append(users, it.User())
+	}
+	return users, it.Err()
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen - breaker открыт, запрос во внешнюю систему не отправлялся
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// RetryPolicy - экспоненциальные повторы с разбросом
+type RetryPolicy struct {
+	// MaxAttempts - всего попыток, включая первую
+	MaxAttempts int
+	// BaseDelay - пауза перед первым повтором, дальше каждый раз удваивается
+	BaseDelay time.Duration
+	// MaxDelay - верхняя граница паузы, 0 - без ограничения
+	MaxDelay time.Duration
+	// Jitter - доля паузы от 0 до 1, на которую она случайно уменьшается, чтобы клиенты не приходили одновременно
+	Jitter float64
+}
+
+// delay - пауза после attempt неудачной попытки
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if p.MaxDelay > 0 && d >= p.MaxDelay {
+			break
+		}
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		d -= time.Duration(p.Jitter * rand.Float64() * float64(d))
+	}
+	return d
+}
+
+// BreakerState - состояние CircuitBreaker
+type BreakerState int
+
+const (
+	// BreakerClosed - запросы идут как обычно
+	BreakerClosed BreakerState = iota
+	// BreakerOpen - запросы сразу завершаются с ErrCircuitOpen
+	BreakerOpen
+	// BreakerHalfOpen - пропускается один пробный запрос
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreaker - после Threshold ошибок подряд перестает пускать запросы на Cooldown,
+// потом пропускает один пробный запрос и по его результату закрывается или снова открывается
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+	// OnStateChange - вызывается при каждой смене состояния
+	OnStateChange func(from, to BreakerState)
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewCircuitBreaker - breaker, который открывается после threshold ошибок подряд
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{Threshold: threshold, Cooldown: cooldown}
+}
+
+// State - текущее состояние
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Allow - можно ли отправить запрос, ErrCircuitOpen если нет
+func (b *CircuitBreaker) Allow() error {
+	var t *transition
+	defer func() { b.notify(t) }()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.Cooldown {
+			return ErrCircuitOpen
+		}
+		t = b.setState(BreakerHalfOpen)
+		b.probing = true
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Record - учитывает результат запроса, пропущенного через Allow
+func (b *CircuitBreaker) Record(success bool) {
+	var t *transition
+	defer func() { b.notify(t) }()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if success {
+		b.failures = 0
+		t = b.setState(BreakerClosed)
+		return
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.Threshold {
+		b.openedAt = time.Now()
+		t = b.setState(BreakerOpen)
+	}
+}
+
+// Release - запрос, пропущенный через Allow, ничего не сказал о внешней системе, например его отменили.
+// Состояние не меняется, но в BreakerHalfOpen следующий пробный запрос снова разрешен
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// transition - смена состояния, о которой сообщается уже после снятия блокировки
+type transition struct {
+	from, to BreakerState
+}
+
+// setState - меняет состояние, вызывается под mu
+func (b *CircuitBreaker) setState(state BreakerState) *transition {
+	if b.state == state {
+		return nil
+	}
+	t := &transition{b.state, state}
+	b.state = state
+	return t
+}
+
+func (b *CircuitBreaker) notify(t *transition) {
+	if t != nil && b.OnStateChange != nil {
+		b.OnStateChange(t.from, t.to)
+	}
+}
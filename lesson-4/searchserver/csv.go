@@ -0,0 +1,46 @@
+package searchserver
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+)
+
+// CSVSource - файл с заголовком в первой строке, поля ищутся по именам колонок
+type CSVSource struct {
+	Path    string
+	Mapping Mapping
+	// Comma - разделитель, по умолчанию ','
+	Comma rune
+}
+
+func (s *CSVSource) Users() ([]User, error) {
+	file, err := os.Open(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	if s.Comma != 0 {
+		reader.Comma = s.Comma
+	}
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("cant unpack %s: %s", s.Path, err)
+	}
+	if len(rows) == 0 {
+		return []User{}, nil
+	}
+
+	header := rows[0]
+	records := make([]map[string]string, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		record := make(map[string]string, len(header))
+		for i, name := range header {
+			record[name] = row[i]
+		}
+		records = append(records, record)
+	}
+	return mapUsers(records, s.Mapping)
+}
@@ -0,0 +1,80 @@
+package searchserver
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// cursor - значения полей сортировки пользователя, с которого продолжается выдача,
+// и параметры запроса, для которого он выдан
+type cursor struct {
+	Order  string `json:"o"`
+	Filter string `json:"f"`
+	Id     int    `json:"i"`
+	Name   string `json:"n,omitempty"`
+	Age    int    `json:"a,omitempty"`
+}
+
+// orderString - сортировка в том виде, в котором она записывается в курсор
+func orderString(keys []sortKey) string {
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key.desc {
+			parts = append(parts, key.field+" desc")
+		} else {
+			parts = append(parts, key.field+" asc")
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// encodeCursor - непрозрачный токен: base64 от json позиции и ее подпись
+func (s *Server) encodeCursor(p searchParams, user *User) string {
+	payload, _ := json.Marshal(cursor{
+		Order:  orderString(p.order),
+		Filter: p.filter.key(),
+		Id:     user.Id,
+		Name:   user.Name,
+		Age:    user.Age,
+	})
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(s.sign(payload))
+}
+
+// parseCursor - проверяет подпись и то, что курсор выдан для той же сортировки и тех же условий отбора.
+// Пустой token - курсора нет, выдача идет с учетом offset
+func (s *Server) parseCursor(token string, p searchParams) (*User, string) {
+	if token == "" {
+		return nil, ""
+	}
+	dot := strings.IndexByte(token, '.')
+	if dot < 0 {
+		return nil, ErrorBadCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(token[:dot])
+	if err != nil {
+		return nil, ErrorBadCursor
+	}
+	sign, err := base64.RawURLEncoding.DecodeString(token[dot+1:])
+	if err != nil || !hmac.Equal(sign, s.sign(payload)) {
+		return nil, ErrorBadCursor
+	}
+
+	c := cursor{}
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, ErrorBadCursor
+	}
+	if len(p.order) == 0 || c.Order != orderString(p.order) || c.Filter != p.filter.key() {
+		return nil, ErrorBadCursor
+	}
+	return &User{Id: c.Id, Name: c.Name, Age: c.Age}, ""
+}
+
+func (s *Server) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.CursorSecret)
+	mac.Write(payload)
+	return mac.Sum(nil)[:16]
+}
@@ -0,0 +1,152 @@
+package searchserver
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MatchSubstring - Query целиком как подстрока Name или About
+	MatchSubstring = "substring"
+	// MatchWords - каждое слово Query есть в Name или About, в любом порядке
+	MatchWords = "words"
+	// MatchPhrase - Query целиком, но только на границах слов
+	MatchPhrase = "phrase"
+)
+
+// filter - условия отбора пользователей
+type filter struct {
+	query      string
+	words      []string
+	mode       string
+	ignoreCase bool
+	gender     string
+	minAge     int
+	maxAge     int
+}
+
+// parseFilter - параметры query, match, ignore_case, gender, min_age, max_age
+func parseFilter(q url.Values) (filter, string) {
+	f := filter{
+		query:  q.Get("query"),
+		mode:   q.Get("match"),
+		gender: q.Get("gender"),
+	}
+	switch f.mode {
+	case "":
+		f.mode = MatchSubstring
+	case MatchSubstring, MatchWords, MatchPhrase:
+	default:
+		return f, ErrorBadMatch
+	}
+	if v := q.Get("ignore_case"); v != "" {
+		var err error
+		if f.ignoreCase, err = strconv.ParseBool(v); err != nil {
+			return f, ErrorBadIgnoreCase
+		}
+	}
+	if f.ignoreCase {
+		f.query = strings.ToLower(f.query)
+	}
+	switch f.mode {
+	case MatchWords:
+		f.words = strings.Fields(f.query)
+	case MatchPhrase:
+		f.query = strings.Join(strings.Fields(f.query), " ")
+	}
+
+	var errCode string
+	if f.minAge, errCode = parseAge(q.Get("min_age")); errCode != "" {
+		return f, errCode
+	}
+	if f.maxAge, errCode = parseAge(q.Get("max_age")); errCode != "" {
+		return f, errCode
+	}
+	if f.maxAge > 0 && f.minAge > f.maxAge {
+		return f, ErrorBadAge
+	}
+	return f, ""
+}
+
+// key - нормализованные условия отбора: запросы с одинаковым key отбирают одних и тех же пользователей
+func (f filter) key() string {
+	query := f.query
+	if f.mode == MatchWords {
+		query = strings.Join(f.words, " ")
+	}
+	return url.Values{
+		"query":       {query},
+		"match":       {f.mode},
+		"ignore_case": {strconv.FormatBool(f.ignoreCase)},
+		"gender":      {f.gender},
+		"min_age":     {strconv.Itoa(f.minAge)},
+		"max_age":     {strconv.Itoa(f.maxAge)},
+	}.Encode()
+}
+
+// parseAge - 0, если граница не задана
+func parseAge(v string) (int, string) {
+	if v == "" {
+		return 0, ""
+	}
+	age, err := strconv.Atoi(v)
+	if err != nil || age < 0 {
+		return 0, ErrorBadAge
+	}
+	return age, ""
+}
+
+func (f *filter) match(user *User) bool {
+	if f.gender != "" && !strings.EqualFold(user.Gender, f.gender) {
+		return false
+	}
+	if user.Age < f.minAge || (f.maxAge > 0 && user.Age > f.maxAge) {
+		return false
+	}
+	if f.query == "" {
+		return true
+	}
+
+	name, about := user.Name, user.About
+	if f.ignoreCase {
+		name, about = strings.ToLower(name), strings.ToLower(about)
+	}
+	switch f.mode {
+	case MatchWords:
+		for _, word := range f.words {
+			if !strings.Contains(name, word) && !strings.Contains(about, word) {
+				return false
+			}
+		}
+		return true
+	case MatchPhrase:
+		return containsPhrase(name, f.query) || containsPhrase(about, f.query)
+	}
+	return strings.Contains(name, f.query) || strings.Contains(about, f.query)
+}
+
+// containsPhrase - phrase встречается в text и не является частью более длинного слова
+func containsPhrase(text, phrase string) bool {
+	for i := 0; i < len(text); {
+		j := strings.Index(text[i:], phrase)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(phrase)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			return true
+		}
+		_, size := utf8.DecodeRuneInString(text[start:])
+		i = start + size
+	}
+	return false
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
@@ -0,0 +1,49 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// JSONSource - файл с массивом объектов, значения полей - строки, числа или bool
+type JSONSource struct {
+	Path    string
+	Mapping Mapping
+}
+
+func (s *JSONSource) Users() ([]User, error) {
+	file, err := os.Open(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rows []map[string]interface{}
+	decoder := json.NewDecoder(file)
+	decoder.UseNumber()
+	if err := decoder.Decode(&rows); err != nil {
+		return nil, fmt.Errorf("cant unpack %s: %s", s.Path, err)
+	}
+
+	records := make([]map[string]string, 0, len(rows))
+	for _, row := range rows {
+		record := make(map[string]string, len(row))
+		for key, value := range row {
+			// вложенные объекты и массивы в User не отображаются, такие поля пропускаются
+			switch v := value.(type) {
+			case string:
+				record[key] = v
+			case json.Number:
+				record[key] = v.String()
+			case bool:
+				record[key] = strconv.FormatBool(v)
+			case nil:
+				record[key] = ""
+			}
+		}
+		records = append(records, record)
+	}
+	return mapUsers(records, s.Mapping)
+}
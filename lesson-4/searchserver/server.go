@@ -0,0 +1,310 @@
+// Package searchserver - внешняя система поиска пользователей, к которой ходит SearchClient
+package searchserver
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	OrderByAsc  = -1
+	OrderByAsIs = 0
+	OrderByDesc = 1
+
+	ErrorBadOrderField = "ErrorBadOrderField"
+	ErrorBadOrderBy    = "ErrorBadOrderBy"
+	ErrorBadLimit      = "ErrorBadLimit"
+	ErrorBadOffset     = "ErrorBadOffset"
+	ErrorBadAge        = "ErrorBadAge"
+	ErrorBadMatch      = "ErrorBadMatch"
+	ErrorBadIgnoreCase = "ErrorBadIgnoreCase"
+	ErrorBadCursor     = "ErrorBadCursor"
+
+	// CursorHeader - курсор последнего отданного пользователя, запрос с ним начнется с этого пользователя.
+	// Отдается только для запросов с сортировкой: у выдачи "как встретилось" нет ключа, с которого можно продолжить
+	CursorHeader = "X-Cursor"
+)
+
+// User - пользователь в том виде, в котором его ждет SearchClient
+type User struct {
+	Id     int
+	Name   string
+	Age    int
+	About  string
+	Gender string
+}
+
+// SearchErrorResponse - тело ответа 400
+type SearchErrorResponse struct {
+	Error string
+	// Field - поле, по которому нельзя сортировать, для ErrorBadOrderField
+	Field string `json:",omitempty"`
+}
+
+// lessFuncs - сравнение пользователей для поддерживаемых order_field, пустое поле - сортировка по Name
+var lessFuncs = map[string]func(a, b *User) bool{
+	"":     func(a, b *User) bool { return a.Name < b.Name },
+	"Name": func(a, b *User) bool { return a.Name < b.Name },
+	"Id":   func(a, b *User) bool { return a.Id < b.Id },
+	"Age":  func(a, b *User) bool { return a.Age < b.Age },
+}
+
+// Server - http.Handler поиска по загруженным один раз пользователям
+type Server struct {
+	// CursorSecret - ключ подписи курсоров, по умолчанию случайный,
+	// тогда курсоры не переживают перезапуск сервера
+	CursorSecret []byte
+
+	users  []User
+	tokens TokenStore
+}
+
+// New - сервер поверх users, запросы с токеном, которого нет в tokens, получают 401
+func New(users []User, tokens TokenStore) *Server {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return &Server{CursorSecret: secret, users: users, tokens: tokens}
+}
+
+// NewFromSource - сервер поверх пользователей, один раз загруженных из src
+func NewFromSource(src UserSource, tokens TokenStore) (*Server, error) {
+	users, err := src.Users()
+	if err != nil {
+		return nil, err
+	}
+	return New(users, tokens), nil
+}
+
+// NewFromXML - сервер поверх файла в формате dataset.xml
+func NewFromXML(path string, tokens TokenStore) (*Server, error) {
+	return NewFromSource(&XMLSource{Path: path}, tokens)
+}
+
+// sortKey - одно поле сортировки
+type sortKey struct {
+	field string
+	less  func(a, b *User) bool
+	desc  bool
+}
+
+// idKey - последний ключ любой сортировки, чтобы порядок был однозначным и по курсору можно было продолжить
+var idKey = sortKey{field: "Id", less: lessFuncs["Id"]}
+
+// searchParams - разобранные параметры запроса
+type searchParams struct {
+	filter filter
+	order  []sortKey
+	// from - позиция из курсора, выдача начинается с нее, offset при этом не учитывается
+	from   *User
+	limit  int
+	offset int
+}
+
+// parseParams - возвращает ответ с кодом ошибки, если параметры некорректны, пустой Error - параметры в порядке
+func parseParams(r *http.Request) (searchParams, SearchErrorResponse) {
+	q := r.URL.Query()
+	p := searchParams{}
+
+	var errCode, field string
+	if v := q.Get("order"); v != "" {
+		p.order, errCode, field = parseOrder(v)
+	} else {
+		p.order, errCode, field = parseOrderField(q.Get("order_field"), q.Get("order_by"))
+	}
+	if errCode != "" {
+		return p, SearchErrorResponse{Error: errCode, Field: field}
+	}
+	if len(p.order) > 0 {
+		p.order = append(p.order, idKey)
+	}
+	if p.filter, errCode = parseFilter(q); errCode != "" {
+		return p, SearchErrorResponse{Error: errCode}
+	}
+
+	var err error
+	p.limit, err = strconv.Atoi(q.Get("limit"))
+	if err != nil || p.limit < 1 {
+		return p, SearchErrorResponse{Error: ErrorBadLimit}
+	}
+	if v := q.Get("offset"); v != "" {
+		p.offset, err = strconv.Atoi(v)
+		if err != nil || p.offset < 0 {
+			return p, SearchErrorResponse{Error: ErrorBadOffset}
+		}
+	}
+	return p, SearchErrorResponse{}
+}
+
+// parseOrderField - сортировка в старом формате: одно поле order_field и направление order_by.
+// Для ErrorBadOrderField возвращается и само поле
+func parseOrderField(field, orderBy string) ([]sortKey, string, string) {
+	less, ok := lessFuncs[field]
+	if !ok {
+		return nil, ErrorBadOrderField, field
+	}
+	if orderBy == "" {
+		return nil, "", ""
+	}
+	by, err := strconv.Atoi(orderBy)
+	if err != nil || by < OrderByAsc || by > OrderByDesc {
+		return nil, ErrorBadOrderBy, ""
+	}
+	if by == OrderByAsIs {
+		return nil, "", ""
+	}
+	return []sortKey{{field: field, less: less, desc: by == OrderByDesc}}, "", ""
+}
+
+// parseOrder - сортировка по нескольким полям: "Age desc,Name asc", направление по умолчанию - asc.
+// Для ErrorBadOrderField возвращается первое неизвестное поле
+func parseOrder(order string) ([]sortKey, string, string) {
+	var keys []sortKey
+	for _, part := range strings.Split(order, ",") {
+		words := strings.Fields(part)
+		if len(words) == 0 || len(words) > 2 {
+			return nil, ErrorBadOrderBy, ""
+		}
+		less, ok := lessFuncs[words[0]]
+		if !ok {
+			return nil, ErrorBadOrderField, words[0]
+		}
+		key := sortKey{field: words[0], less: less}
+		if len(words) == 2 {
+			switch strings.ToLower(words[1]) {
+			case "asc":
+			case "desc":
+				key.desc = true
+			default:
+				return nil, ErrorBadOrderBy, ""
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, "", ""
+}
+
+// compare - сравнение по ключам по порядку, следующий ключ используется, только если предыдущие равны
+func compare(keys []sortKey, a, b *User) bool {
+	for _, key := range keys {
+		x, y := a, b
+		if key.desc {
+			x, y = b, a
+		}
+		if key.less(x, y) {
+			return true
+		}
+		if key.less(y, x) {
+			return false
+		}
+	}
+	return false
+}
+
+// search - пользователи, подходящие под параметры, с учетом сортировки, offset и limit
+func (s *Server) search(p searchParams) []User {
+	result := make([]User, 0, len(s.users))
+	for i := range s.users {
+		if p.filter.match(&s.users[i]) {
+			result = append(result, s.users[i])
+		}
+	}
+
+	if len(p.order) > 0 {
+		sort.SliceStable(result, func(i, j int) bool { return compare(p.order, &result[i], &result[j]) })
+	}
+	if p.from != nil {
+		start := sort.Search(len(result), func(i int) bool { return !compare(p.order, &result[i], p.from) })
+		result = result[start:]
+		p.offset = 0
+	}
+
+	if p.offset >= len(result) {
+		return []User{}
+	}
+	result = result[p.offset:]
+	if len(result) > p.limit {
+		result = result[:p.limit]
+	}
+	return result
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.tokens == nil || !s.tokens.Valid(requestToken(r)) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	p, errResp := parseParams(r)
+	if errResp.Error == "" {
+		p.from, errResp.Error = s.parseCursor(r.URL.Query().Get("cursor"), p)
+	}
+	if errResp.Error != "" {
+		writeJSON(w, http.StatusBadRequest, errResp)
+		return
+	}
+
+	users := s.search(p)
+	cursor := ""
+	if len(p.order) > 0 && len(users) > 0 {
+		cursor = s.encodeCursor(p, &users[len(users)-1])
+		w.Header().Set(CursorHeader, cursor)
+	}
+	writeUsers(w, r, users, cursor)
+}
+
+// requestToken - токен из заголовка AccessToken или из Authorization: Bearer
+func requestToken(r *http.Request) string {
+	if token := r.Header.Get("AccessToken"); token != "" {
+		return token
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return auth[len("Bearer "):]
+	}
+	return ""
+}
+
+// writeUsers - ответ 200 с ETag, если у клиента уже есть такой ответ - 304 без тела.
+// ETag учитывает и курсор: после смены CursorSecret тело то же, а курсор у клиента уже недействителен
+func writeUsers(w http.ResponseWriter, r *http.Request, users []User, cursor string) {
+	body, err := json.Marshal(users)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	hash := sha256.New()
+	hash.Write(body)
+	hash.Write([]byte(cursor))
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)[:8]) + `"`
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body); err != nil {
+		log.Println("cant write response:", err)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		log.Println("cant write response:", err)
+	}
+}
@@ -0,0 +1,323 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	srv, err := NewFromXML("../dataset.xml", NewStaticTokens("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func get(t *testing.T, ts *httptest.Server, token string, params url.Values) *http.Response {
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"?"+params.Encode(), nil)
+	req.Header.Set("AccessToken", token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestLoadXML(t *testing.T) {
+	users, err := LoadXML("../dataset.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 35 {
+		t.Fatalf("expected 35 users, got %d", len(users))
+	}
+	if users[0].Id != 0 || users[0].Name != "Boyd Wolf" || users[0].Age != 22 || users[0].Gender != "male" {
+		t.Errorf("unexpected first user %+v", users[0])
+	}
+}
+
+func TestServerSearch(t *testing.T) {
+	ts := newTestServer(t)
+	cases := []struct {
+		params url.Values
+		check  func(users []User) bool
+	}{
+		// возраст сравнивается как число, а не как строка
+		{url.Values{"limit": {"35"}, "order_field": {"Age"}, "order_by": {"-1"}}, func(users []User) bool {
+			for i := 1; i < len(users); i++ {
+				if users[i-1].Age > users[i].Age {
+					return false
+				}
+			}
+			return len(users) == 35
+		}},
+		// offset применяется после сортировки
+		{url.Values{"limit": {"2"}, "offset": {"1"}, "order_field": {"Id"}, "order_by": {"1"}}, func(users []User) bool {
+			return len(users) == 2 && users[0].Id == 33 && users[1].Id == 32
+		}},
+		{url.Values{"limit": {"5"}, "query": {"Boyd"}}, func(users []User) bool {
+			return len(users) == 1 && users[0].Id == 0
+		}},
+		{url.Values{"limit": {"5"}, "offset": {"100"}}, func(users []User) bool {
+			return users != nil && len(users) == 0
+		}},
+		// при равном возрасте порядок определяет следующее поле
+		{url.Values{"limit": {"35"}, "order": {"Age desc, Name asc"}}, func(users []User) bool {
+			for i := 1; i < len(users); i++ {
+				a, b := users[i-1], users[i]
+				if a.Age < b.Age || (a.Age == b.Age && a.Name > b.Name) {
+					return false
+				}
+			}
+			return len(users) == 35
+		}},
+		{url.Values{"limit": {"35"}, "gender": {"Female"}, "min_age": {"30"}, "max_age": {"35"}}, func(users []User) bool {
+			for _, user := range users {
+				if user.Gender != "female" || user.Age < 30 || user.Age > 35 {
+					return false
+				}
+			}
+			return len(users) > 0
+		}},
+		{url.Values{"limit": {"5"}, "query": {"boyd WOLF"}, "ignore_case": {"true"}}, func(users []User) bool {
+			return len(users) == 1 && users[0].Id == 0
+		}},
+		{url.Values{"limit": {"5"}, "query": {"wolf boyd"}, "ignore_case": {"1"}, "match": {"words"}}, func(users []User) bool {
+			return len(users) == 1 && users[0].Id == 0
+		}},
+		// "Boy" - часть слова Boyd, как фраза не совпадает
+		{url.Values{"limit": {"5"}, "query": {"Boy"}, "match": {"phrase"}}, func(users []User) bool {
+			return len(users) == 0
+		}},
+		{url.Values{"limit": {"5"}, "query": {"Boyd  Wolf"}, "match": {"phrase"}}, func(users []User) bool {
+			return len(users) == 1 && users[0].Id == 0
+		}},
+	}
+	for i, item := range cases {
+		resp := get(t, ts, "test", item.params)
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("[%d] unexpected status %d", i, resp.StatusCode)
+			continue
+		}
+		var users []User
+		if err := json.NewDecoder(resp.Body).Decode(&users); err != nil {
+			t.Errorf("[%d] cant decode: %s", i, err)
+			continue
+		}
+		if !item.check(users) {
+			t.Errorf("[%d] unexpected result %+v", i, users)
+		}
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	ts := newTestServer(t)
+	cases := []struct {
+		token  string
+		params url.Values
+		status int
+		error  string
+	}{
+		{"bad", url.Values{"limit": {"1"}}, http.StatusUnauthorized, ""},
+		{"", url.Values{"limit": {"1"}}, http.StatusUnauthorized, ""},
+		{"test", url.Values{"limit": {"1"}, "order_field": {"About"}}, http.StatusBadRequest, ErrorBadOrderField},
+		{"test", url.Values{"limit": {"1"}, "order_by": {"2"}}, http.StatusBadRequest, ErrorBadOrderBy},
+		{"test", url.Values{"limit": {"0"}}, http.StatusBadRequest, ErrorBadLimit},
+		{"test", url.Values{"limit": {"1"}, "offset": {"-1"}}, http.StatusBadRequest, ErrorBadOffset},
+		{"test", url.Values{"limit": {"1"}, "order": {"Age desc,About"}}, http.StatusBadRequest, ErrorBadOrderField},
+		{"test", url.Values{"limit": {"1"}, "order": {"Age down"}}, http.StatusBadRequest, ErrorBadOrderBy},
+		{"test", url.Values{"limit": {"1"}, "order": {"Age,"}}, http.StatusBadRequest, ErrorBadOrderBy},
+		{"test", url.Values{"limit": {"1"}, "min_age": {"40"}, "max_age": {"30"}}, http.StatusBadRequest, ErrorBadAge},
+		{"test", url.Values{"limit": {"1"}, "min_age": {"old"}}, http.StatusBadRequest, ErrorBadAge},
+		{"test", url.Values{"limit": {"1"}, "match": {"regexp"}}, http.StatusBadRequest, ErrorBadMatch},
+		{"test", url.Values{"limit": {"1"}, "ignore_case": {"maybe"}}, http.StatusBadRequest, ErrorBadIgnoreCase},
+	}
+	for i, item := range cases {
+		resp := get(t, ts, item.token, item.params)
+		if resp.StatusCode != item.status {
+			t.Errorf("[%d] expected status %d, got %d", i, item.status, resp.StatusCode)
+			continue
+		}
+		if item.error == "" {
+			continue
+		}
+		errResp := SearchErrorResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil || errResp.Error != item.error {
+			t.Errorf("[%d] expected error %s, got %+v (%v)", i, item.error, errResp, err)
+		}
+		if item.error == ErrorBadOrderField && errResp.Field != "About" {
+			t.Errorf("[%d] expected bad field About, got %+v", i, errResp)
+		}
+	}
+}
+
+func TestServerBearer(t *testing.T) {
+	ts := newTestServer(t)
+	for header, expected := range map[string]int{
+		"Bearer test": http.StatusOK,
+		"Bearer bad":  http.StatusUnauthorized,
+		"Basic test":  http.StatusUnauthorized,
+	} {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"?limit=1", nil)
+		req.Header.Set("Authorization", header)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != expected {
+			t.Errorf("[%s] expected %d, got %d", header, expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestStaticTokens(t *testing.T) {
+	tokens := NewStaticTokens("a")
+	tokens.Add("b")
+	tokens.Revoke("a")
+	if tokens.Valid("a") || !tokens.Valid("b") || tokens.Valid("") {
+		t.Error("unexpected token state")
+	}
+}
+
+func TestContainsPhrase(t *testing.T) {
+	cases := []struct {
+		text, phrase string
+		expected     bool
+	}{
+		{"nulla cillum", "nulla", true},
+		{"nullam cillum", "nulla", false},
+		{"nullam, nulla.", "nulla", true},
+		{"ad ex", "ex", true},
+		{"index", "ex", false},
+		{"щука и карп", "карп", true},
+	}
+	for _, item := range cases {
+		if containsPhrase(item.text, item.phrase) != item.expected {
+			t.Errorf("containsPhrase(%q, %q) != %v", item.text, item.phrase, item.expected)
+		}
+	}
+}
+
+func TestServerCursor(t *testing.T) {
+	users, err := LoadXML("../dataset.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := New(users, NewStaticTokens("test"))
+	ts := httptest.NewServer(first)
+	defer ts.Close()
+
+	params := url.Values{"limit": {"5"}, "order": {"Age asc"}}
+	resp := get(t, ts, "test", params)
+	page := []User{}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	token := resp.Header.Get(CursorHeader)
+	if len(page) != 5 || token == "" {
+		t.Fatalf("expected 5 users and cursor, got %d %q", len(page), token)
+	}
+
+	// между страницами из данных пропал первый пользователь: offset сдвинулся бы, курсор - нет
+	changed := New(users, NewStaticTokens("test"))
+	changed.CursorSecret = first.CursorSecret
+	for i := range users {
+		if users[i].Id == page[0].Id {
+			changed.users = append(append([]User{}, users[:i]...), users[i+1:]...)
+		}
+	}
+	tsChanged := httptest.NewServer(changed)
+	defer tsChanged.Close()
+
+	params.Set("cursor", token)
+	params.Set("offset", "100")
+	resp = get(t, tsChanged, "test", params)
+	next := []User{}
+	if err := json.NewDecoder(resp.Body).Decode(&next); err != nil {
+		t.Fatal(err)
+	}
+	if len(next) == 0 || next[0].Id != page[4].Id {
+		t.Errorf("expected page to start with %+v, got %+v", page[4], next)
+	}
+
+	badCursors := []url.Values{
+		{"limit": {"5"}, "order": {"Age asc"}, "cursor": {token + "x"}},
+		{"limit": {"5"}, "order": {"Age asc"}, "cursor": {"garbage"}},
+		{"limit": {"5"}, "order": {"Name asc"}, "cursor": {token}},
+		{"limit": {"5"}, "cursor": {token}},
+		{"limit": {"5"}, "order": {"Age asc"}, "query": {"Boyd"}, "cursor": {token}},
+		{"limit": {"5"}, "order": {"Age asc"}, "gender": {"male"}, "cursor": {token}},
+		{"limit": {"5"}, "order": {"Age asc"}, "min_age": {"30"}, "cursor": {token}},
+		{"limit": {"5"}, "order": {"Age asc"}, "ignore_case": {"true"}, "cursor": {token}},
+	}
+	for i, params := range badCursors {
+		resp := get(t, tsChanged, "test", params)
+		errResp := SearchErrorResponse{}
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		if resp.StatusCode != http.StatusBadRequest || errResp.Error != ErrorBadCursor {
+			t.Errorf("[%d] expected ErrorBadCursor, got %d %+v", i, resp.StatusCode, errResp)
+		}
+	}
+
+	// те же условия отбора в другой записи курсор принимает
+	resp = get(t, tsChanged, "test", url.Values{"limit": {"5"}, "order": {"Age  asc"}, "match": {MatchSubstring},
+		"ignore_case": {"0"}, "min_age": {"0"}, "cursor": {token}})
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected cursor for equivalent params to be accepted, got %d", resp.StatusCode)
+	}
+
+	// без сортировки курсор не отдается
+	resp = get(t, ts, "test", url.Values{"limit": {"5"}})
+	if resp.Header.Get(CursorHeader) != "" {
+		t.Error("unexpected cursor for unordered request")
+	}
+}
+
+func TestServerETag(t *testing.T) {
+	ts := newTestServer(t)
+	params := url.Values{"limit": {"5"}, "query": {"Boyd"}}
+	resp := get(t, ts, "test", params)
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with ETag, got %d %q", resp.StatusCode, etag)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"?"+params.Encode(), nil)
+	req.Header.Set("AccessToken", "test")
+	req.Header.Set("If-None-Match", etag)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("expected 304, got %d", resp.StatusCode)
+	}
+
+	params.Del("query")
+	resp = get(t, ts, "test", params)
+	if resp.Header.Get("ETag") == etag {
+		t.Error("expected different ETag for different result")
+	}
+
+	// после смены ключа тело то же, но курсор другой - ETag тоже должен смениться
+	srv, err := NewFromXML("../dataset.xml", NewStaticTokens("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tsOrdered := httptest.NewServer(srv)
+	defer tsOrdered.Close()
+	params = url.Values{"limit": {"5"}, "order": {"Age asc"}}
+	etag = get(t, tsOrdered, "test", params).Header.Get("ETag")
+	srv.CursorSecret = []byte("rotated")
+	if get(t, tsOrdered, "test", params).Header.Get("ETag") == etag {
+		t.Error("expected different ETag after CursorSecret rotation")
+	}
+}
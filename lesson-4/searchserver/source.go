@@ -0,0 +1,119 @@
+package searchserver
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// UserSource - хранилище, из которого сервер один раз загружает пользователей
+type UserSource interface {
+	Users() ([]User, error)
+}
+
+// Mapping - имена полей источника для полей User. Пустое имя - в источнике такого поля нет.
+// Если Name пустое, имя собирается из FirstName и LastName через пробел
+type Mapping struct {
+	Id        string
+	Name      string
+	FirstName string
+	LastName  string
+	Age       string
+	About     string
+	Gender    string
+}
+
+// DefaultMapping - раскладка полей dataset.xml, используется, если Mapping не задан
+var DefaultMapping = Mapping{
+	Id:        "id",
+	FirstName: "first_name",
+	LastName:  "last_name",
+	Age:       "age",
+	About:     "about",
+	Gender:    "gender",
+}
+
+func (m Mapping) orDefault() Mapping {
+	if m == (Mapping{}) {
+		return DefaultMapping
+	}
+	return m
+}
+
+// fields - имена полей, которые нужно прочитать из источника
+func (m Mapping) fields() []string {
+	fields := make([]string, 0, 7)
+	for _, name := range []string{m.Id, m.Name, m.FirstName, m.LastName, m.Age, m.About, m.Gender} {
+		if name != "" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// user - собирает User из одной записи источника
+func (m Mapping) user(record map[string]string) (User, error) {
+	user := User{}
+	get := func(field string) (string, error) {
+		if field == "" {
+			return "", nil
+		}
+		value, ok := record[field]
+		if !ok {
+			return "", fmt.Errorf("field %q not found", field)
+		}
+		return value, nil
+	}
+	atoi := func(field string) (int, error) {
+		value, err := get(field)
+		if err != nil || field == "" {
+			return 0, err
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, fmt.Errorf("bad %s %q", field, value)
+		}
+		return n, nil
+	}
+
+	var err error
+	if user.Id, err = atoi(m.Id); err != nil {
+		return user, err
+	}
+	if user.Age, err = atoi(m.Age); err != nil {
+		return user, err
+	}
+	if user.About, err = get(m.About); err != nil {
+		return user, err
+	}
+	if user.Gender, err = get(m.Gender); err != nil {
+		return user, err
+	}
+	if m.Name != "" {
+		user.Name, err = get(m.Name)
+		return user, err
+	}
+	first, err := get(m.FirstName)
+	if err != nil {
+		return user, err
+	}
+	last, err := get(m.LastName)
+	if err != nil {
+		return user, err
+	}
+	user.Name = first + " " + last
+	return user, nil
+}
+
+// mapUsers - переводит записи источника в User, ошибка указывает номер записи
+func mapUsers(records []map[string]string, m Mapping) ([]User, error) {
+	m = m.orDefault()
+	users := make([]User, 0, len(records))
+	for i, record := range records {
+		user, err := m.user(record)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %s", i, err)
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
@@ -0,0 +1,160 @@
+package searchserver
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var sourceUsers = []User{
+	{Id: 1, Name: "Boyd Wolf", Age: 22, About: "about boyd", Gender: "male"},
+	{Id: 2, Name: "Hilda Mayer", Age: 21, About: "", Gender: "female"},
+}
+
+// customMapping - источник хранит имя одним полем и называет колонки по-своему
+var customMapping = Mapping{Id: "user_id", Name: "full_name", Age: "years", About: "bio", Gender: "sex"}
+
+func writeSourceFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestUserSources(t *testing.T) {
+	cases := []struct {
+		name   string
+		source UserSource
+	}{
+		{"json", &JSONSource{
+			Path: writeSourceFile(t, "users.json", `[
+				{"id": 1, "first_name": "Boyd", "last_name": "Wolf", "age": 22, "about": "about boyd", "gender": "male"},
+				{"id": "2", "first_name": "Hilda", "last_name": "Mayer", "age": 21, "about": null, "gender": "female", "extra": true}
+			]`),
+		}},
+		{"json mapping", &JSONSource{
+			Path: writeSourceFile(t, "users.json", `[
+				{"user_id": 1, "full_name": "Boyd Wolf", "years": 22, "bio": "about boyd", "sex": "male"},
+				{"user_id": 2, "full_name": "Hilda Mayer", "years": 21, "bio": "", "sex": "female"}
+			]`),
+			Mapping: customMapping,
+		}},
+		{"csv", &CSVSource{
+			Path: writeSourceFile(t, "users.csv", "id,first_name,last_name,age,about,gender\n"+
+				"1,Boyd,Wolf,22,about boyd,male\n2,Hilda,Mayer,21,,female\n"),
+		}},
+		{"csv mapping", &CSVSource{
+			Path: writeSourceFile(t, "users.csv", "sex;full_name;user_id;years;bio\n"+
+				"male;Boyd Wolf;1;22;about boyd\nfemale;Hilda Mayer;2;21;\n"),
+			Mapping: customMapping,
+			Comma:   ';',
+		}},
+		{"xml", &XMLSource{
+			Path: writeSourceFile(t, "users.xml", `<root>
+				<row><id>1</id><age>22</age><first_name>Boyd</first_name><last_name>Wolf</last_name><gender>male</gender><about>about boyd</about></row>
+				<row><id>2</id><age>21</age><first_name>Hilda</first_name><last_name>Mayer</last_name><gender>female</gender><about></about></row>
+			</root>`),
+		}},
+		{"sql", &SQLSource{DB: openTestDB(t), Table: "users", Mapping: customMapping}},
+	}
+	for _, item := range cases {
+		users, err := item.source.Users()
+		if err != nil {
+			t.Errorf("[%s] unexpected error: %s", item.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(users, sourceUsers) {
+			t.Errorf("[%s] expected %+v, got %+v", item.name, sourceUsers, users)
+		}
+	}
+}
+
+func TestUserSourceErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		source UserSource
+		error  string
+	}{
+		{"missing field", &CSVSource{
+			Path: writeSourceFile(t, "users.csv", "id,first_name,age,about,gender\n1,Boyd,22,,male\n"),
+		}, `row 0: field "last_name" not found`},
+		{"bad age", &JSONSource{
+			Path:    writeSourceFile(t, "users.json", `[{"user_id": 1, "full_name": "Boyd", "years": "old", "bio": "", "sex": ""}]`),
+			Mapping: customMapping,
+		}, `row 0: bad years "old"`},
+		{"bad table", &SQLSource{DB: openTestDB(t), Table: "users; DROP TABLE users"}, `bad sql identifier "users; DROP TABLE users"`},
+		{"bad column", &SQLSource{DB: openTestDB(t), Table: "users", Mapping: Mapping{Id: "id", Name: "name FROM secrets --"}},
+			`bad sql identifier "name FROM secrets --"`},
+	}
+	for _, item := range cases {
+		_, err := item.source.Users()
+		if err == nil || err.Error() != item.error {
+			t.Errorf("[%s] expected error %q, got %v", item.name, item.error, err)
+		}
+	}
+}
+
+// testDriver - драйвер database/sql, который на любой запрос отдает sourceUsers в колонках customMapping
+type testDriver struct{}
+
+type testConn struct{}
+
+type testStmt struct {
+	query string
+}
+
+type testRows struct {
+	rows [][]driver.Value
+}
+
+var testQuery = "SELECT user_id, full_name, years, bio, sex FROM users"
+
+func init() {
+	sql.Register("searchserver-test", testDriver{})
+}
+
+func openTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("searchserver-test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func (testDriver) Open(name string) (driver.Conn, error) { return testConn{}, nil }
+
+func (testConn) Prepare(query string) (driver.Stmt, error) { return &testStmt{query: query}, nil }
+func (testConn) Close() error                              { return nil }
+func (testConn) Begin() (driver.Tx, error)                 { return nil, driver.ErrSkip }
+
+func (s *testStmt) Close() error  { return nil }
+func (s *testStmt) NumInput() int { return 0 }
+func (s *testStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, driver.ErrSkip
+}
+func (s *testStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if s.query != testQuery {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return &testRows{rows: [][]driver.Value{
+		{int64(1), "Boyd Wolf", int64(22), []byte("about boyd"), "male"},
+		{int64(2), "Hilda Mayer", int64(21), nil, "female"},
+	}}, nil
+}
+
+func (r *testRows) Columns() []string { return []string{"user_id", "full_name", "years", "bio", "sex"} }
+func (r *testRows) Close() error      { return nil }
+func (r *testRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,56 @@
+package searchserver
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// identifier - имя таблицы или колонки, которое можно подставить в запрос без кавычек
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SQLSource - таблица в базе, из нее читаются только колонки из Mapping.
+// Имена таблицы и колонок подставляются в запрос как есть, поэтому допускаются только
+// буквы, цифры и _, иначе Users возвращает ошибку, не выполняя запрос
+type SQLSource struct {
+	DB      *sql.DB
+	Table   string
+	Mapping Mapping
+}
+
+func (s *SQLSource) Users() ([]User, error) {
+	m := s.Mapping.orDefault()
+	columns := m.fields()
+	for _, name := range append([]string{s.Table}, columns...) {
+		if !identifier.MatchString(name) {
+			return nil, fmt.Errorf("bad sql identifier %q", name)
+		}
+	}
+	rows, err := s.DB.Query(fmt.Sprintf("SELECT %s FROM %s", strings.Join(columns, ", "), s.Table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []map[string]string
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		record := make(map[string]string, len(columns))
+		for i, column := range columns {
+			record[column] = values[i].String
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return mapUsers(records, m)
+}
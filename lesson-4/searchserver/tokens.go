@@ -0,0 +1,48 @@
+package searchserver
+
+import (
+	"sync"
+)
+
+// TokenStore - проверка AccessToken
+type TokenStore interface {
+	Valid(token string) bool
+}
+
+// StaticTokens - набор токенов в памяти, можно менять во время работы сервера
+type StaticTokens struct {
+	mu     sync.RWMutex
+	tokens map[string]struct{}
+}
+
+func NewStaticTokens(tokens ...string) *StaticTokens {
+	s := &StaticTokens{tokens: map[string]struct{}{}}
+	for _, token := range tokens {
+		s.tokens[token] = struct{}{}
+	}
+	return s
+}
+
+func (s *StaticTokens) Valid(token string) bool {
+	if token == "" {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.tokens[token]
+	return ok
+}
+
+// Add - добавляет токен
+func (s *StaticTokens) Add(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[token] = struct{}{}
+}
+
+// Revoke - удаляет токен
+func (s *StaticTokens) Revoke(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, token)
+}
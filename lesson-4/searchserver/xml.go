@@ -0,0 +1,53 @@
+package searchserver
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+)
+
+// xmlRow - запись в формате dataset.xml, поля читаются все, нужные выбирает Mapping
+type xmlRow struct {
+	Fields []struct {
+		XMLName xml.Name
+		Value   string `xml:",chardata"`
+	} `xml:",any"`
+}
+
+type xmlRoot struct {
+	Rows []xmlRow `xml:"row"`
+}
+
+// XMLSource - файл в формате dataset.xml: корневой элемент с записями <row>
+type XMLSource struct {
+	Path    string
+	Mapping Mapping
+}
+
+func (s *XMLSource) Users() ([]User, error) {
+	file, err := os.Open(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	root := xmlRoot{}
+	if err := xml.NewDecoder(file).Decode(&root); err != nil {
+		return nil, fmt.Errorf("cant unpack %s: %s", s.Path, err)
+	}
+
+	records := make([]map[string]string, 0, len(root.Rows))
+	for _, row := range root.Rows {
+		record := make(map[string]string, len(row.Fields))
+		for _, field := range row.Fields {
+			record[field.XMLName.Local] = field.Value
+		}
+		records = append(records, record)
+	}
+	return mapUsers(records, s.Mapping)
+}
+
+// LoadXML - читает пользователей из файла в формате dataset.xml
+func LoadXML(path string) ([]User, error) {
+	return (&XMLSource{Path: path}).Users()
+}
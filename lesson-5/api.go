@@ -1,10 +1,15 @@
 package main
 
+//go:generate go run ./handlers_gen . handlers.go
+//go:generate go run ./handlers_gen -mode client -package apiclient . apiclient/client.go
+//go:generate go run ./handlers_gen -mode openapi -api MyApi . openapi.json
+
 import (
 	"context"
 	"fmt"
 	"net/http"
 	"sync"
+	"time"
 )
 
 // вы можете использовать ApiError в коде, который получается в результате генерации
@@ -29,6 +34,7 @@ const (
 type MyApi struct {
 	statuses map[string]int
 	users    map[string]*User
+	tokens   map[string]string // логин пользователя по значению X-Auth
 	nextID   uint64
 	mu       *sync.RWMutex
 }
@@ -48,6 +54,9 @@ func NewMyApi() *MyApi {
 				Status:   statusAdmin,
 			},
 		},
+		tokens: map[string]string{
+			"100500": "rvasily",
+		},
 		nextID: 43,
 		mu:     &sync.RWMutex{},
 	}
@@ -75,6 +84,18 @@ type NewUser struct {
 	ID uint64 `json:"id"`
 }
 
+// Authenticate - пользователь, которому выдан токен из X-Auth.
+// Методы с "auth": true получают его через PrincipalFromContext
+func (h *MyApi) Authenticate(r *http.Request) (interface{}, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	user, exist := h.users[h.tokens[r.Header.Get("X-Auth")]]
+	if !exist {
+		return nil, fmt.Errorf("bad token")
+	}
+	return user, nil
+}
+
 // apigen:api {"url": "/user/profile", "auth": false}
 func (h *MyApi) Profile(ctx context.Context, in ProfileParams) (*User, error) {
 
@@ -118,6 +139,94 @@ func (h *MyApi) Create(ctx context.Context, in CreateParams) (*NewUser, error) {
 	return &NewUser{id}, nil
 }
 
+type StatusParams struct {
+	Login string `apivalidator:"required"`
+}
+
+type SetStatusParams struct {
+	Login  string `apivalidator:"required"`
+	Status string `apivalidator:"required,enum=user|moderator|admin"`
+}
+
+type UserStatus struct {
+	Login  string `json:"login"`
+	Status string `json:"status"`
+}
+
+// apigen:api {"url": "/user/status", "auth": false, "method": "GET"}
+func (h *MyApi) Status(ctx context.Context, in StatusParams) (*UserStatus, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	user, exist := h.users[in.Login]
+	if !exist {
+		return nil, ApiError{http.StatusNotFound, fmt.Errorf("user not exist")}
+	}
+	for name, status := range h.statuses {
+		if status == user.Status {
+			return &UserStatus{Login: user.Login, Status: name}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown status %d", user.Status)
+}
+
+// apigen:api {"url": "/user/status", "auth": true, "method": ["PUT", "PATCH"]}
+func (h *MyApi) SetStatus(ctx context.Context, in SetStatusParams) (*UserStatus, error) {
+	principal, _ := PrincipalFromContext(ctx)
+	if author, ok := principal.(*User); !ok || author.Status < statusAdmin {
+		return nil, ApiError{http.StatusForbidden, fmt.Errorf("not enough rights")}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	user, exist := h.users[in.Login]
+	if !exist {
+		return nil, ApiError{http.StatusNotFound, fmt.Errorf("user not exist")}
+	}
+	user.Status = h.statuses[in.Status]
+	return &UserStatus{Login: user.Login, Status: in.Status}, nil
+}
+
+type UserByIDParams struct {
+	ID int `apivalidator:"path=id,min=1"`
+}
+
+type LoginProfileParams struct {
+	Login string `apivalidator:"path=login,required"`
+}
+
+// apigen:api {"url": "/user/by-id/{id}", "auth": false, "method": "GET"}
+func (h *MyApi) UserByID(ctx context.Context, in UserByIDParams) (*User, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, user := range h.users {
+		if user.ID == uint64(in.ID) {
+			return user, nil
+		}
+	}
+	return nil, ApiError{http.StatusNotFound, fmt.Errorf("user not exist")}
+}
+
+type MyProfileParams struct {
+}
+
+// apigen:api {"url": "/user/me/profile", "auth": true, "method": "GET"}
+func (h *MyApi) MyProfile(ctx context.Context, in MyProfileParams) (*User, error) {
+	principal, _ := PrincipalFromContext(ctx)
+	user, ok := principal.(*User)
+	if !ok {
+		return nil, fmt.Errorf("unknown principal")
+	}
+	return user, nil
+}
+
+// apigen:api {"url": "/user/{login}/profile", "auth": false, "method": "GET"}
+func (h *MyApi) LoginProfile(ctx context.Context, in LoginProfileParams) (*User, error) {
+	return h.Profile(ctx, ProfileParams{Login: in.Login})
+}
+
 // 2-я часть
 // это похожая структура, с теми же методами, но у них другие параметры!
 // код, созданный вашим кодогенератором работает с конкретной струткурой, про другие ничего не знает
@@ -130,6 +239,17 @@ func NewOtherApi() *OtherApi {
 	return &OtherApi{}
 }
 
+func init() {
+	// своей проверки у OtherApi нет, для него используется общая
+	RegisterAuthenticator(AuthenticatorFunc(func(r *http.Request) (interface{}, error) {
+		token := r.Header.Get("X-Auth")
+		if token != "100500" {
+			return nil, fmt.Errorf("bad token")
+		}
+		return token, nil
+	}))
+}
+
 type OtherCreateParams struct {
 	Username string `apivalidator:"required,min=3"`
 	Name     string `apivalidator:"paramname=account_name"`
@@ -153,3 +273,36 @@ func (h *OtherApi) Create(ctx context.Context, in OtherCreateParams) (*OtherUser
 		Level:    in.Level,
 	}, nil
 }
+
+type OtherFindParams struct {
+	Names  []string  `apivalidator:"paramname=name,max=3,enum=warrior|sorcerer|rouge"`
+	Online *bool     `apivalidator:"paramname=online"`
+	Guild  *string   `apivalidator:"paramname=guild,min=3"`
+	Level  int64     `apivalidator:"min=1,max=50,default=1"`
+	Limit  uint      `apivalidator:"max=100,default=10"`
+	Rating float64   `apivalidator:"min=0,max=5"`
+	Since  time.Time `apivalidator:"required"`
+}
+
+type OtherFindResult struct {
+	Names  []string  `json:"names"`
+	Online *bool     `json:"online"`
+	Guild  *string   `json:"guild"`
+	Level  int64     `json:"level"`
+	Limit  uint      `json:"limit"`
+	Rating float64   `json:"rating"`
+	Since  time.Time `json:"since"`
+}
+
+// apigen:api {"url": "/user/find", "auth": false}
+func (h *OtherApi) Find(ctx context.Context, in OtherFindParams) (*OtherFindResult, error) {
+	return &OtherFindResult{
+		Names:  in.Names,
+		Online: in.Online,
+		Guild:  in.Guild,
+		Level:  in.Level,
+		Limit:  in.Limit,
+		Rating: in.Rating,
+		Since:  in.Since,
+	}, nil
+}
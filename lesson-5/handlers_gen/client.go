@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type clientTemplateData struct {
+	Name       string
+	AuthHeader string
+}
+
+type clientMethodTemplateData struct {
+	ReceiverType string
+	FuncName     string
+	ParamsType   string
+	Method       string
+	MethodConst  string
+	URL          string
+	Path         string
+	Auth         bool
+	Encode       string
+	// Defaults - params with default, their zero values can not be sent
+	Defaults string
+	// Result - return type of method, Elem - its element type if it is pointer
+	Result string
+	Elem   string
+}
+
+// clientImports - imports of generated client, depend on field types
+type clientImports map[string]bool
+
+func (imports clientImports) String() string {
+	names := make([]string, 0, len(imports))
+	for name := range imports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString("import (\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "\t%q\n", name)
+	}
+	b.WriteString(")\n")
+	return b.String()
+}
+
+// clientMethod - http method client uses: the first of config, GET if method takes any
+func clientMethod(config *handlerConfig) string {
+	if len(config.Method) > 0 {
+		return config.Method[0]
+	}
+	return "GET"
+}
+
+// clientPath - go expression of url with path params of in substituted
+func clientPath(fset *token.FileSet, url string, fields map[string]*ast.Field, imports clientImports) (string, error) {
+	var parts []string
+	literal := ""
+	for i, segment := range strings.Split(url, "/") {
+		if i > 0 {
+			literal += "/"
+		}
+		if !strings.HasPrefix(segment, "{") {
+			literal += segment
+			continue
+		}
+		field := fields[segment[1:len(segment)-1]]
+		ft, err := getFieldType(fset, field)
+		if err != nil {
+			return "", err
+		}
+		value := "in." + field.Names[0].Name
+		if ft.Pointer {
+			value = "*" + value
+		}
+		parts = append(parts, strconv.Quote(literal), "url.PathEscape("+formatValue(ft.Kind, value, imports)+")")
+		literal = ""
+	}
+	if literal != "" || len(parts) == 0 {
+		parts = append(parts, strconv.Quote(literal))
+	}
+	return strings.Join(parts, " + "), nil
+}
+
+// formatValue - go expression of string param from value of supported kind
+func formatValue(kind, value string, imports clientImports) string {
+	format := fieldKinds[kind].Format
+	if strings.HasPrefix(format, "strconv.") {
+		imports["strconv"] = true
+	}
+	if kind == "time.Time" {
+		imports["time"] = true
+	}
+	return fmt.Sprintf(format, value)
+}
+
+// createEncodeCode - code putting fields of params into values, path fields go to url instead
+func createEncodeCode(fset *token.FileSet, params *ast.StructType, imports clientImports) (string, error) {
+	var code string
+	for _, field := range params.Fields.List {
+		if field.Tag == nil {
+			continue
+		}
+		tag := reflect.StructTag(field.Tag.Value[1 : len(field.Tag.Value)-1])
+		options := parseStructTag(tag.Get("apivalidator"))
+		if _, ok := options["path"]; ok {
+			continue
+		}
+		ft, err := getFieldType(fset, field)
+		if err != nil {
+			return "", err
+		}
+		fieldName := field.Names[0].Name
+		queryName := getQueryName(options, fieldName)
+		switch {
+		case ft.Slice:
+			value := formatValue(ft.Kind, "v", imports)
+			code += fmt.Sprintf("\tfor _, v := range in.%s {\n\t\tvalues.Add(%q, %s)\n\t}\n", fieldName, queryName, value)
+		case ft.Pointer:
+			value := formatValue(ft.Kind, "*in."+fieldName, imports)
+			code += fmt.Sprintf("\tif in.%s != nil {\n\t\tvalues.Set(%q, %s)\n\t}\n", fieldName, queryName, value)
+		default:
+			value := formatValue(ft.Kind, "in."+fieldName, imports)
+			nonZero := fmt.Sprintf(fieldKinds[ft.Kind].NonZero, "in."+fieldName)
+			code += fmt.Sprintf("\tif %s {\n\t\tvalues.Set(%q, %s)\n\t}\n", nonZero, queryName, value)
+		}
+	}
+	return code, nil
+}
+
+// defaultParams - "Field (default v)" for params with default, client does not send zero values,
+// so server unpacks them as default and explicit zero of such param can not be sent
+func defaultParams(params *ast.StructType) []string {
+	var defaults []string
+	for _, field := range params.Fields.List {
+		if field.Tag == nil || len(field.Names) != 1 {
+			continue
+		}
+		tag := reflect.StructTag(field.Tag.Value[1 : len(field.Tag.Value)-1])
+		options := parseStructTag(tag.Get("apivalidator"))
+		if _, ok := options["path"]; ok {
+			continue
+		}
+		if v, ok := options["default"]; ok {
+			defaults = append(defaults, fmt.Sprintf("%s (default %s)", field.Names[0].Name, v))
+		}
+	}
+	return defaults
+}
+
+// createClientMethod - client method calling api method with the same name and signature
+func createClientMethod(fset *token.FileSet, node *ast.File, method apiMethod, imports clientImports) (string, error) {
+	fd, config := method.Func, method.Config
+	if err := checkPathParams(fset, node, fd, config.URL); err != nil {
+		return "", err
+	}
+	params := findStruct(node, getParamType(fd))
+	encode, err := createEncodeCode(fset, params, imports)
+	if err != nil {
+		return "", err
+	}
+	path, err := clientPath(fset, config.URL, pathFields(params), imports)
+	if err != nil {
+		return "", err
+	}
+
+	httpMethod := clientMethod(config)
+	result := fd.Type.Results.List[0].Type
+	data := clientMethodTemplateData{
+		ReceiverType: getReceiverType(fd),
+		FuncName:     fd.Name.Name,
+		ParamsType:   getParamType(fd),
+		Method:       httpMethod,
+		MethodConst:  knownMethods[httpMethod],
+		URL:          config.URL,
+		Path:         path,
+		Auth:         config.Auth,
+		Encode:       strings.TrimSuffix(encode, "\n"),
+		Defaults:     strings.Join(defaultParams(params), ", "),
+		Result:       types.ExprString(result),
+	}
+	if star, ok := result.(*ast.StarExpr); ok {
+		data.Elem = types.ExprString(star.X)
+	}
+	var tmp bytes.Buffer
+	if err := clientMethodTpl.Execute(&tmp, data); err != nil {
+		return "", err
+	}
+	return tmp.String(), nil
+}
+
+// typeCopier - declarations of api package types used by client, for client in its own package
+type typeCopier struct {
+	fset    *token.FileSet
+	node    *ast.File
+	imports clientImports
+	seen    map[string]bool
+	decls   bytes.Buffer
+}
+
+// findTypeSpec - type declared in file
+func findTypeSpec(node *ast.File, name string) *ast.TypeSpec {
+	for _, decl := range node.Decls {
+		if g, ok := decl.(*ast.GenDecl); ok {
+			for _, spec := range g.Specs {
+				if ts, ok := spec.(*ast.TypeSpec); ok && ts.Name.Name == name {
+					return ts
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// copyType - declaration of type named by ident and of types it refers to
+func (c *typeCopier) copyType(ident *ast.Ident) error {
+	if types.Universe.Lookup(ident.Name) != nil || c.seen[ident.Name] {
+		return nil
+	}
+	spec := findTypeSpec(c.node, ident.Name)
+	if spec == nil {
+		return positionError(c.fset, ident.Pos(), "type %s not found", ident.Name)
+	}
+	c.seen[ident.Name] = true
+	fmt.Fprintf(&c.decls, "\n// %s - copy of %s.%s\n", ident.Name, c.node.Name.Name, ident.Name)
+	err := printer.Fprint(&c.decls, c.fset, &ast.GenDecl{Tok: token.TYPE, Specs: []ast.Spec{spec}})
+	if err != nil {
+		return err
+	}
+	c.decls.WriteString("\n")
+	return c.copyExpr(spec.Type)
+}
+
+// copyExpr - copies types of the api package used in type expression
+func (c *typeCopier) copyExpr(expr ast.Expr) error {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return c.copyType(t)
+	case *ast.StarExpr:
+		return c.copyExpr(t.X)
+	case *ast.ArrayType:
+		return c.copyExpr(t.Elt)
+	case *ast.MapType:
+		if err := c.copyExpr(t.Key); err != nil {
+			return err
+		}
+		return c.copyExpr(t.Value)
+	case *ast.StructType:
+		for _, field := range t.Fields.List {
+			if err := c.copyExpr(field.Type); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *ast.InterfaceType:
+		if len(t.Methods.List) == 0 {
+			return nil
+		}
+	case *ast.SelectorExpr:
+		if types.ExprString(t) == "time.Time" {
+			c.imports["time"] = true
+			return nil
+		}
+	}
+	return positionError(c.fset, expr.Pos(), "type %s can not be copied into client package", types.ExprString(expr))
+}
+
+// writeClient - generate client struct for every api struct, with method for every api method, into file.
+// Client in package other than package of api gets copies of params and result types and its own ApiError
+func writeClient(fset *token.FileSet, node *ast.File, methods []apiMethod, authHeader, pkgName, fileName string) error {
+	imports := clientImports{
+		"context": true, "encoding/json": true, "errors": true, "fmt": true,
+		"io": true, "net/http": true, "net/url": true, "strings": true,
+	}
+	out := &bytes.Buffer{}
+	fmt.Fprint(out, clientHelpersFunc)
+
+	clients := map[string]bool{}
+	for _, method := range methods {
+		name := getReceiverType(method.Func)
+		if !clients[name] {
+			clients[name] = true
+			err := clientTpl.Execute(out, clientTemplateData{Name: name, AuthHeader: authHeader})
+			if err != nil {
+				return err
+			}
+		}
+		code, err := createClientMethod(fset, node, method, imports)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(out, code)
+	}
+
+	if pkgName == "" {
+		pkgName = node.Name.Name
+	}
+	if pkgName != node.Name.Name {
+		copier := &typeCopier{fset: fset, node: node, imports: imports, seen: map[string]bool{}}
+		for _, method := range methods {
+			fd := method.Func
+			if err := copier.copyExpr(fd.Type.Params.List[1].Type); err != nil {
+				return err
+			}
+			if err := copier.copyExpr(fd.Type.Results.List[0].Type); err != nil {
+				return err
+			}
+		}
+		fmt.Fprint(out, clientApiErrorType)
+		copier.decls.WriteTo(out)
+	}
+
+	code := &bytes.Buffer{}
+	fmt.Fprint(code, generatedHeader)
+	fmt.Fprintln(code, "package "+pkgName+"\n")
+	fmt.Fprint(code, imports)
+	code.Write(out.Bytes())
+	formatted, err := format.Source(code.Bytes())
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, formatted, 0644)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"testing"
+)
+
+func TestClientPackageCompiles(t *testing.T) {
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, "../api.go", nil, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	methods, err := getApiMethods(fset, node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileName := filepath.Join(t.TempDir(), "apiclient", "client.go")
+	if err := writeClient(fset, node, methods, "X-Auth", "apiclient", fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	// client package has no imports of api package, so it type checks by itself
+	clientFset := token.NewFileSet()
+	file, err := parser.ParseFile(clientFset, fileName, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(clientFset, "source", nil)}
+	pkg, err := conf.Check("apiclient", clientFset, []*ast.File{file}, nil)
+	if err != nil {
+		t.Fatalf("generated client does not compile: %v", err)
+	}
+	for _, name := range []string{"MyApiClient", "OtherApiClient", "ApiError", "CreateParams", "User", "OtherFindResult"} {
+		if pkg.Scope().Lookup(name) == nil {
+			t.Errorf("expected %s in client package", name)
+		}
+	}
+	if pkg.Scope().Lookup("MyApi") != nil {
+		t.Error("api struct itself must not be copied")
+	}
+}
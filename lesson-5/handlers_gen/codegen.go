@@ -3,42 +3,36 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"go/ast"
-	"go/parser"
 	"go/token"
 	"log"
 	"os"
 	"reflect"
+	"sort"
 	"strings"
 )
 
 const generatorLabel = "// apigen:api"
 
-var serveHTTPRoutes = map[string]string{}
-
 // handlerConfig - settings of method
 type handlerConfig struct {
-	URL    string `json:"url"`
-	Auth   bool   `json:"auth"`
-	Method string `json:"method"`
-}
-
-type routeTemplateData struct {
-	URL      string
-	FuncName string
+	URL    string      `json:"url"`
+	Auth   bool        `json:"auth"`
+	Method httpMethods `json:"method"`
 }
 
 type serveHTTPTemplateData struct {
 	StructName string
 	Router     string
+	Patterns   []*route
 }
 
 type wrapperTemplateData struct {
 	ReceiverType string
 	ParamsType   string
 	FuncName     string
-	MethodCheck  string
 	AuthCheck    string
 }
 
@@ -52,6 +46,17 @@ type unpackData struct {
 	DefaultVal string
 }
 
+type typedUnpackData struct {
+	QueryName  string
+	FieldName  string
+	DefaultVal string
+	Parse      string
+	Desc       string
+	Pointer    bool
+	// Source - values or pathValues
+	Source string
+}
+
 // getReceiverType - get type name of receiver struct
 func getReceiverType(fd *ast.FuncDecl) string {
 	st, ok1 := fd.Recv.List[0].Type.(*ast.StarExpr)
@@ -83,9 +88,6 @@ func createWrapper(fd *ast.FuncDecl, config *handlerConfig) (string, error) {
 		ParamsType:   pt,
 		FuncName:     fd.Name.Name,
 	}
-	if config.Method == "POST" {
-		data.MethodCheck = methodCheckTemplate
-	}
 	if config.Auth {
 		data.AuthCheck = authCheckTemplate
 	}
@@ -96,18 +98,6 @@ func createWrapper(fd *ast.FuncDecl, config *handlerConfig) (string, error) {
 	return tmp.String(), nil
 }
 
-// createRoute - generate router code
-func createRoute(fd *ast.FuncDecl, config *handlerConfig) error {
-	typeName := getReceiverType(fd)
-	var tmp bytes.Buffer
-	err := routeTpl.Execute(&tmp, routeTemplateData{config.URL, fd.Name.Name})
-	if err != nil {
-		return err
-	}
-	serveHTTPRoutes[typeName] += tmp.String()
-	return nil
-}
-
 // parseStructTag - parse struct tag and return options
 func parseStructTag(tag string) map[string]string {
 	result := map[string]string{}
@@ -128,6 +118,9 @@ func parseStructTag(tag string) map[string]string {
 
 // getQueryName - get name of query parameter from struct tag
 func getQueryName(options map[string]string, fieldName string) string {
+	if pathName, ok := options["path"]; ok {
+		return pathName
+	}
 	if queryName, ok := options["paramname"]; ok {
 		return fmt.Sprintf(`%s`, queryName)
 	}
@@ -150,9 +143,16 @@ func getDefaultValue(options map[string]string, typeName string) string {
 }
 
 // createUnpackCode - create unpack code for single field
-func createUnpackCode(field *ast.Field, options map[string]string) (string, error) {
+func createUnpackCode(fset *token.FileSet, field *ast.Field, options map[string]string) (string, error) {
+	ft, err := getFieldType(fset, field)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := options["path"]; ok || !ft.plain() {
+		return createTypedUnpackCode(fset, field, ft, options)
+	}
 	fieldName := field.Names[0].Name
-	fieldType := field.Type.(*ast.Ident).Name
+	fieldType := ft.Kind
 	var tmp bytes.Buffer
 	data := unpackData{
 		QueryName: getQueryName(options, fieldName),
@@ -178,11 +178,18 @@ func createUnpackCode(field *ast.Field, options map[string]string) (string, erro
 	}
 }
 
-// createUnpackCode - create unpack code for field
-func createValidateCode(field *ast.Field, options map[string]string) string {
+// createValidateCode - create validate code for field
+func createValidateCode(fset *token.FileSet, field *ast.Field, options map[string]string) (string, error) {
+	ft, err := getFieldType(fset, field)
+	if err != nil {
+		return "", err
+	}
+	if !ft.plain() {
+		return createTypedValidateCode(fset, field, ft, options)
+	}
 	var code string
 	fieldName := field.Names[0].Name
-	filedType := field.Type.(*ast.Ident).Name
+	filedType := ft.Kind
 	queryName := getQueryName(options, fieldName)
 
 	if _, ok := options["required"]; ok {
@@ -193,6 +200,14 @@ func createValidateCode(field *ast.Field, options map[string]string) string {
 		}
 	}
 
+	for _, option := range []string{"min", "max"} {
+		if limit, ok := options[option]; ok {
+			if err := checkBound(fset, field, ft, option, limit); err != nil {
+				return "", err
+			}
+		}
+	}
+
 	if min, ok := options["min"]; ok {
 		if filedType == "int" {
 			code += fmt.Sprintf(minTemplateInt, fieldName, min, queryName, min)
@@ -211,13 +226,12 @@ func createValidateCode(field *ast.Field, options map[string]string) string {
 		code += fmt.Sprintf(checkEnumTemplate, param, fieldName, queryName)
 	}
 
-	return code
+	return code, nil
 }
 
-// createParamMethod - create Unpack and Validate methods for handler params
-func createParamMethod(node *ast.File, typeName string) (string, string, error) {
-	var unpackCode string
-	var validateCode string
+// createParamMethod - create Unpack and Validate methods for handler params,
+// usesTime - some field is time.Time and generated code needs "time" import
+func createParamMethod(fset *token.FileSet, node *ast.File, typeName string) (unpackCode, validateCode string, usesTime bool, err error) {
 	for _, f := range node.Decls {
 		g, ok := f.(*ast.GenDecl)
 		if !ok { // Ignore not *ast.GenDecl items
@@ -241,6 +255,9 @@ func createParamMethod(node *ast.File, typeName string) (string, string, error)
 				log.Fatal(err)
 			}
 			unpackCode = tmp.String()
+			if len(pathFields(currStruct)) > 0 {
+				unpackCode += pathValuesTemplate
+			}
 
 			// Init validate function
 			tmp.Reset()
@@ -250,87 +267,194 @@ func createParamMethod(node *ast.File, typeName string) (string, string, error)
 			}
 			validateCode = tmp.String()
 
+			readsValues := false
 			for _, field := range currStruct.Fields.List {
 				if field.Tag != nil {
 					// Get tag option
 					tag := reflect.StructTag(field.Tag.Value[1 : len(field.Tag.Value)-1])
 					tagOptions := parseStructTag(tag.Get("apivalidator"))
+					if _, ok := tagOptions["path"]; !ok {
+						readsValues = true
+					}
+					ft, err := getFieldType(fset, field)
+					if err != nil {
+						return "", "", false, err
+					}
+					usesTime = usesTime || ft.Kind == "time.Time"
 
 					// Create unpack code for field
-					unpack, err := createUnpackCode(field, tagOptions)
+					unpack, err := createUnpackCode(fset, field, tagOptions)
 					if err != nil {
-						log.Fatal(err)
+						return "", "", false, err
 					}
 					unpackCode += unpack
 
 					// Create validate code
-					validateCode += createValidateCode(field, tagOptions)
+					validate, err := createValidateCode(fset, field, tagOptions)
+					if err != nil {
+						return "", "", false, err
+					}
+					validateCode += validate
 				}
 			}
+			if !readsValues {
+				// all params come from path or there are no params
+				unpackCode += "\n\t_ = values\n"
+			}
 			unpackCode += "\treturn nil\n}\n"
 			validateCode += "\treturn nil\n}\n"
 		}
 	}
-	return unpackCode, validateCode, nil
+	return unpackCode, validateCode, usesTime, nil
+}
+
+// apiMethod - function marked with generatorLabel
+type apiMethod struct {
+	Func   *ast.FuncDecl
+	Config *handlerConfig
+}
+
+// getApiMethods - functions with generatorLabel and their configs in order of declaration, files are ordered by name
+func getApiMethods(fset *token.FileSet, node *ast.File) ([]apiMethod, error) {
+	var methods []apiMethod
+	for _, d := range node.Decls {
+		fd, ok := d.(*ast.FuncDecl)
+		if !ok || fd.Doc == nil {
+			continue
+		}
+		for _, comment := range fd.Doc.List {
+			if !strings.HasPrefix(comment.Text, generatorLabel) {
+				// Ignore functions without label
+				continue
+			}
+			config := new(handlerConfig)
+			err := json.Unmarshal([]byte(comment.Text[len(generatorLabel):]), config)
+			if err != nil {
+				return nil, positionError(fset, comment.Pos(), "bad config: %s", err)
+			}
+			methods = append(methods, apiMethod{fd, config})
+		}
+	}
+	return methods, nil
 }
 
 func main() {
-	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, os.Args[1], nil, parser.ParseComments)
+	mode := flag.String("mode", "go", "output: go - http handlers, client - go client, openapi - OpenAPI 3 document")
+	apiName := flag.String("api", "", "api struct for openapi mode, may be omitted if package has only one")
+	authHeader := flag.String("auth-header", "X-Auth", "header checked by Authenticator, for client and security scheme of openapi mode")
+	pkgName := flag.String("package", "", "package of client mode output, by default package of api, other package gets copies of used types")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: codegen [-mode go|client|openapi] [-api name] [-auth-header name] [-package name] package|api.go output")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	fset, node, err := loadPackage(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	methods, err := getApiMethods(fset, node)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	out, _ := os.Create(os.Args[2])
-	fmt.Fprintln(out, "package "+node.Name.Name+"\n")
-	fmt.Fprintf(out, importStr)
-	fmt.Fprintf(out, checkEnumFunc)
-
-	for _, d := range node.Decls {
-		fd, ok := d.(*ast.FuncDecl)
-		if ok {
-			if fd.Doc != nil {
-				for _, comment := range fd.Doc.List {
-					if !strings.HasPrefix(comment.Text, generatorLabel) {
-						// Ignore functions without label
-						continue
-					}
-					config := new(handlerConfig)
-					err := json.Unmarshal([]byte(comment.Text[14:]), config)
-					if err != nil {
-						log.Fatal(err)
-					}
+	switch *mode {
+	case "go":
+		err = writeHandlers(fset, node, methods, flag.Arg(1))
+	case "client":
+		err = writeClient(fset, node, methods, *authHeader, *pkgName, flag.Arg(1))
+	case "openapi":
+		err = writeOpenAPI(fset, node, methods, *apiName, *authHeader, flag.Arg(1))
+	default:
+		err = fmt.Errorf("unknown mode %s", *mode)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
 
-					// Create http wrapper for function
-					wrapper, err := createWrapper(fd, config)
-					if err != nil {
-						log.Fatal(err)
-					}
-					fmt.Fprintln(out, wrapper)
+// writeHandlers - generate http wrappers, params methods and ServeHTTP into file
+func writeHandlers(fset *token.FileSet, node *ast.File, methods []apiMethod, fileName string) error {
+	// imports depend on field types, so code is collected first
+	out := &bytes.Buffer{}
+	fmt.Fprint(out, checkEnumFunc)
+	fmt.Fprint(out, jsonHelpersFunc)
+	fmt.Fprint(out, authHelpersFunc)
+	fmt.Fprint(out, methodHelpersFunc)
+	fmt.Fprint(out, pathHelpersFunc)
+
+	usesTime := false
+	for _, method := range methods {
+		fd, config := method.Func, method.Config
+
+		// Path params of url must be bound to fields of params
+		err := checkPathParams(fset, node, fd, config.URL)
+		if err != nil {
+			return err
+		}
 
-					// Create router for function
-					err = createRoute(fd, config)
-					if err != nil {
-						log.Fatal(err)
-					}
+		// Create http wrapper for function
+		wrapper, err := createWrapper(fd, config)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, wrapper)
 
-					// Create unpack and validate method for function parameter
-					unpackCode, validateCode, err := createParamMethod(node, getParamType(fd))
-					if err != nil {
-						log.Fatal(err)
-					}
-					fmt.Fprintln(out, unpackCode)
-					fmt.Fprintln(out, validateCode)
-				}
-			}
+		// Create unpack and validate method for function parameter
+		unpackCode, validateCode, paramsUseTime, err := createParamMethod(fset, node, getParamType(fd))
+		if err != nil {
+			return err
 		}
+		usesTime = usesTime || paramsUseTime
+		fmt.Fprintln(out, unpackCode)
+		fmt.Fprintln(out, validateCode)
 	}
 
 	// Create ServeHTTP methods
-	for k, v := range serveHTTPRoutes {
-		err := serveHTTPTpl.Execute(out, serveHTTPTemplateData{k, v})
+	routes, err := buildRoutes(fset, methods)
+	if err != nil {
+		return err
+	}
+	apiNames := make([]string, 0, len(routes))
+	for name := range routes {
+		apiNames = append(apiNames, name)
+	}
+	sort.Strings(apiNames)
+	for _, k := range apiNames {
+		var static, patterns []*route
+		for _, r := range routes[k] {
+			if r.Pattern {
+				patterns = append(patterns, r)
+			} else {
+				static = append(static, r)
+			}
+		}
+		router, err := createRouter(static)
+		if err != nil {
+			return err
+		}
+		err = serveHTTPTpl.Execute(out, serveHTTPTemplateData{k, router, patterns})
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 	}
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	fmt.Fprint(file, generatedHeader)
+	fmt.Fprintln(file, "package "+node.Name.Name+"\n")
+	imports := importStr
+	if usesTime {
+		imports = strings.Replace(imports, "\t\"strings\"\n", "\t\"strings\"\n\t\"time\"\n", 1)
+	}
+	fmt.Fprint(file, imports)
+	_, err = out.WriteTo(file)
+	return err
 }
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fieldKind - how value of supported type is parsed from string param
+type fieldKind struct {
+	// Parse - statement declaring %[1]s and err from raw string %[2]s, empty for string
+	Parse string
+	// Check - validates default value from struct tag at generation time
+	Check func(v string) error
+	// Desc - type name for error message
+	Desc string
+	// Format - string param from value %s, for generated client
+	Format string
+	// NonZero - condition that value %s is set, zero values are not sent by client like server does not unpack them
+	NonZero string
+}
+
+var fieldKinds = map[string]fieldKind{
+	"string": {Desc: "string", Format: "%s", NonZero: `%s != ""`},
+	"int": {
+		Parse:   "%[1]s, err := strconv.Atoi(%[2]s)",
+		Check:   func(v string) error { _, err := strconv.Atoi(v); return err },
+		Desc:    "int",
+		Format:  "strconv.Itoa(%s)",
+		NonZero: "%s != 0",
+	},
+	"int64": {
+		Parse:   "%[1]s, err := strconv.ParseInt(%[2]s, 10, 64)",
+		Check:   func(v string) error { _, err := strconv.ParseInt(v, 10, 64); return err },
+		Desc:    "int64",
+		Format:  "strconv.FormatInt(%s, 10)",
+		NonZero: "%s != 0",
+	},
+	"uint": {
+		Parse:   "parsed%[1]s, err := strconv.ParseUint(%[2]s, 10, 0)\n\t\t%[1]s := uint(parsed%[1]s)",
+		Check:   func(v string) error { _, err := strconv.ParseUint(v, 10, 0); return err },
+		Desc:    "uint",
+		Format:  "strconv.FormatUint(uint64(%s), 10)",
+		NonZero: "%s != 0",
+	},
+	"float64": {
+		Parse:   "%[1]s, err := strconv.ParseFloat(%[2]s, 64)",
+		Check:   func(v string) error { _, err := strconv.ParseFloat(v, 64); return err },
+		Desc:    "float",
+		Format:  "strconv.FormatFloat(%s, 'g', -1, 64)",
+		NonZero: "%s != 0",
+	},
+	"bool": {
+		Parse:   "%[1]s, err := strconv.ParseBool(%[2]s)",
+		Check:   func(v string) error { _, err := strconv.ParseBool(v); return err },
+		Desc:    "bool",
+		Format:  "strconv.FormatBool(%s)",
+		NonZero: "%s",
+	},
+	"time.Time": {
+		Parse:   "%[1]s, err := time.Parse(time.RFC3339, %[2]s)",
+		Check:   func(v string) error { _, err := time.Parse(time.RFC3339, v); return err },
+		Desc:    "RFC3339 time",
+		Format:  "%s.Format(time.RFC3339Nano)",
+		NonZero: "!%s.IsZero()",
+	},
+}
+
+// fieldType - type of params struct field: supported kind, optionally behind pointer or in slice
+type fieldType struct {
+	Kind    string
+	Pointer bool
+	Slice   bool
+}
+
+// plain - int and string fields without pointer and slice, they use original templates
+func (ft fieldType) plain() bool {
+	return !ft.Pointer && !ft.Slice && (ft.Kind == "int" || ft.Kind == "string")
+}
+
+// numeric - min and max compare value itself, not its length
+func (ft fieldType) numeric() bool {
+	return ft.Kind != "string" && ft.Kind != "bool" && ft.Kind != "time.Time"
+}
+
+// bounded - min and max are supported: they limit len of string, count of slice or numeric value
+func (ft fieldType) bounded() bool {
+	return ft.Slice || ft.Kind == "string" || ft.numeric()
+}
+
+// positionError - error with file:line:column of node, like go tools print
+func positionError(fset *token.FileSet, pos token.Pos, format string, args ...interface{}) error {
+	return fmt.Errorf("%s: %s", fset.Position(pos), fmt.Sprintf(format, args...))
+}
+
+// kindName - name of scalar type expression, "" if type is not supported
+func kindName(expr ast.Expr) string {
+	var name string
+	switch t := expr.(type) {
+	case *ast.Ident:
+		name = t.Name
+	case *ast.SelectorExpr:
+		if pkg, ok := t.X.(*ast.Ident); ok {
+			name = pkg.Name + "." + t.Sel.Name
+		}
+	}
+	if _, ok := fieldKinds[name]; ok {
+		return name
+	}
+	return ""
+}
+
+// getFieldType - resolve type of field, error with position for unsupported types.
+// Params are flat: embedded fields and nested structs are not supported, every field is one query param
+func getFieldType(fset *token.FileSet, field *ast.Field) (fieldType, error) {
+	ft := fieldType{}
+	switch {
+	case len(field.Names) == 0:
+		return ft, positionError(fset, field.Pos(), "embedded field %s is not supported", types.ExprString(field.Type))
+	case len(field.Names) > 1:
+		return ft, positionError(fset, field.Pos(), "fields %s share tag, declare them one per line",
+			joinNames(field.Names))
+	}
+	expr := field.Type
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		ft.Pointer, expr = true, t.X
+	case *ast.ArrayType:
+		if t.Len == nil {
+			ft.Slice, expr = true, t.Elt
+		}
+	}
+	if _, ok := expr.(*ast.StructType); ok {
+		return ft, positionError(fset, field.Type.Pos(), "nested struct of field %s is not supported", field.Names[0].Name)
+	}
+	ft.Kind = kindName(expr)
+	if ft.Kind == "" {
+		return ft, positionError(fset, field.Type.Pos(), "unsupported type %s of field %s",
+			types.ExprString(field.Type), field.Names[0].Name)
+	}
+	return ft, nil
+}
+
+func joinNames(names []*ast.Ident) string {
+	list := make([]string, len(names))
+	for i, name := range names {
+		list[i] = name.Name
+	}
+	return strings.Join(list, ", ")
+}
+
+// createTypedUnpackCode - unpack code for pointer, slice and non int/string fields
+func createTypedUnpackCode(fset *token.FileSet, field *ast.Field, ft fieldType, options map[string]string) (string, error) {
+	fieldName := field.Names[0].Name
+	kind := fieldKinds[ft.Kind]
+	data := typedUnpackData{
+		QueryName: getQueryName(options, fieldName),
+		FieldName: fieldName,
+		Desc:      kind.Desc,
+		Pointer:   ft.Pointer,
+		Source:    "values",
+	}
+	if _, ok := options["path"]; ok {
+		if ft.Slice {
+			return "", positionError(fset, field.Pos(), "path param can not be slice, field %s", fieldName)
+		}
+		data.Source = "pathValues"
+	}
+	if kind.Parse != "" {
+		data.Parse = fmt.Sprintf(kind.Parse, "val"+fieldName, "raw"+fieldName)
+	} else {
+		data.Parse = fmt.Sprintf("val%[1]s := raw%[1]s", fieldName)
+	}
+
+	if v, ok := options["default"]; ok {
+		if err := checkDefault(fset, field, ft, v); err != nil {
+			return "", err
+		}
+		if kind.Check != nil {
+			data.DefaultVal = v
+		} else {
+			data.DefaultVal = strconv.Quote(v)
+		}
+	}
+
+	tpl := scalarTpl
+	if ft.Slice {
+		tpl = sliceTpl
+	}
+	var tmp bytes.Buffer
+	if err := tpl.Execute(&tmp, data); err != nil {
+		return "", err
+	}
+	return tmp.String(), nil
+}
+
+// checkDefault - default is set only for scalar fields and must be value of field type
+func checkDefault(fset *token.FileSet, field *ast.Field, ft fieldType, v string) error {
+	fieldName := field.Names[0].Name
+	if ft.Pointer || ft.Slice || ft.Kind == "time.Time" {
+		return positionError(fset, field.Pos(), "default is not supported for field %s of type %s",
+			fieldName, types.ExprString(field.Type))
+	}
+	if check := fieldKinds[ft.Kind].Check; check != nil && check(v) != nil {
+		return positionError(fset, field.Pos(), "bad default %q for field %s", v, fieldName)
+	}
+	return nil
+}
+
+// checkBound - min and max of numeric field must be value of field type,
+// for len of string and count of slice they must be non-negative int
+func checkBound(fset *token.FileSet, field *ast.Field, ft fieldType, option, limit string) error {
+	var valid bool
+	if ft.Slice || ft.Kind == "string" {
+		n, err := strconv.Atoi(limit)
+		valid = err == nil && n >= 0
+	} else {
+		valid = fieldKinds[ft.Kind].Check(limit) == nil
+	}
+	if !valid {
+		return positionError(fset, field.Pos(), "bad %s %q for field %s of type %s",
+			option, limit, field.Names[0].Name, types.ExprString(field.Type))
+	}
+	return nil
+}
+
+// createTypedValidateCode - validate code for pointer, slice and non int/string fields
+func createTypedValidateCode(fset *token.FileSet, field *ast.Field, ft fieldType, options map[string]string) (string, error) {
+	var code string
+	fieldName := field.Names[0].Name
+	queryName := getQueryName(options, fieldName)
+	unsupported := func(option string) error {
+		return positionError(fset, field.Pos(), "%s is not supported for field %s of type %s",
+			option, fieldName, types.ExprString(field.Type))
+	}
+
+	// value - expression checked by min, max and enum, guard - condition when it is set
+	value, guard := "in."+fieldName, ""
+	if ft.Pointer {
+		value, guard = "*in."+fieldName, "in."+fieldName+" != nil && "
+	}
+
+	if _, ok := options["required"]; ok {
+		switch {
+		case ft.Pointer:
+			code += fmt.Sprintf(requiredTemplatePtr, fieldName, queryName)
+		case ft.Slice:
+			code += fmt.Sprintf(requiredTemplateSlice, fieldName, queryName)
+		case ft.Kind == "time.Time":
+			code += fmt.Sprintf(requiredTemplateTime, fieldName, queryName)
+		case ft.Kind == "bool":
+			return "", unsupported("required")
+		default:
+			code += fmt.Sprintf(requiredTemplateInt, fieldName, queryName)
+		}
+	}
+
+	for _, bound := range []struct{ option, op, desc string }{{"min", "<", ">="}, {"max", ">", "<="}} {
+		limit, ok := options[bound.option]
+		if !ok {
+			continue
+		}
+		if !ft.bounded() {
+			return "", unsupported(bound.option)
+		}
+		if err := checkBound(fset, field, ft, bound.option, limit); err != nil {
+			return "", err
+		}
+		switch {
+		case ft.Slice:
+			code += fmt.Sprintf(boundTemplate, "len(in."+fieldName+")", bound.op, limit, queryName+" count", bound.desc, limit)
+		case ft.Kind == "string":
+			code += fmt.Sprintf(boundTemplate, guard+"len("+value+")", bound.op, limit, queryName+" len", bound.desc, limit)
+		default:
+			code += fmt.Sprintf(boundTemplate, guard+value, bound.op, limit, queryName, bound.desc, limit)
+		}
+	}
+
+	if enumString, ok := options["enum"]; ok {
+		if ft.Kind != "string" {
+			return "", unsupported("enum")
+		}
+		enum := "[]string{" + quoteAll(strings.Split(enumString, "|")) + "}"
+		switch {
+		case ft.Slice:
+			code += fmt.Sprintf(checkEnumSliceTemplate, enum, fieldName, queryName)
+		default:
+			code += fmt.Sprintf(checkEnumGuardTemplate, enum, guard, value, queryName)
+		}
+	}
+	return code, nil
+}
+
+func quoteAll(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = strconv.Quote(v)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const fieldsSrc = `package api
+
+import "time"
+
+type Base struct {
+	Login string ` + "`apivalidator:\"required\"`" + `
+}
+
+type EmbeddedParams struct {
+	Base ` + "`apivalidator:\"required\"`" + `
+}
+
+type SharedParams struct {
+	From, To int ` + "`apivalidator:\"min=0\"`" + `
+}
+
+type NestedParams struct {
+	Filter struct{ Age int } ` + "`apivalidator:\"required\"`" + `
+}
+
+type TimeParams struct {
+	Since time.Time ` + "`apivalidator:\"required\"`" + `
+}
+
+type PlainParams struct {
+	Limit int ` + "`apivalidator:\"min=1\"`" + `
+}
+`
+
+func parseSrc(t *testing.T, src string) (*token.FileSet, *ast.File) {
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, "api.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fset, node
+}
+
+func TestParamFieldErrors(t *testing.T) {
+	fset, node := parseSrc(t, fieldsSrc)
+	cases := []struct {
+		params string
+		error  string
+	}{
+		{"EmbeddedParams", "api.go:10:2: embedded field Base is not supported"},
+		{"SharedParams", "api.go:14:2: fields From, To share tag, declare them one per line"},
+		{"NestedParams", "api.go:18:9: nested struct of field Filter is not supported"},
+	}
+	for _, item := range cases {
+		_, _, _, err := createParamMethod(fset, node, item.params)
+		if err == nil || err.Error() != item.error {
+			t.Errorf("[%s] expected error %q, got %v", item.params, item.error, err)
+		}
+	}
+}
+
+func TestParamUsesTime(t *testing.T) {
+	fset, node := parseSrc(t, fieldsSrc)
+	unpack, _, usesTime, err := createParamMethod(fset, node, "TimeParams")
+	if err != nil || !usesTime || !strings.Contains(unpack, "time.Parse") {
+		t.Errorf("expected time params, got %v, %v", usesTime, err)
+	}
+	// time of previous params does not leak into next ones
+	_, _, usesTime, err = createParamMethod(fset, node, "PlainParams")
+	if err != nil || usesTime {
+		t.Errorf("expected params without time, got %v, %v", usesTime, err)
+	}
+}
+
+const boundsSrc = `package api
+
+type BoundsParams struct {
+	Count int ` + "`apivalidator:\"min=1.5\"`" + `
+	Level int64 ` + "`apivalidator:\"max=1e3\"`" + `
+	Limit uint ` + "`apivalidator:\"min=-1\"`" + `
+	Login string ` + "`apivalidator:\"min=-3\"`" + `
+	Names []string ` + "`apivalidator:\"max=2.5\"`" + `
+	Guild *string ` + "`apivalidator:\"max=x\"`" + `
+	Rating float64 ` + "`apivalidator:\"min=0.5\"`" + `
+}
+`
+
+func TestBoundErrors(t *testing.T) {
+	fset, node := parseSrc(t, boundsSrc)
+	fields := map[string]*ast.Field{}
+	ast.Inspect(node, func(n ast.Node) bool {
+		if field, ok := n.(*ast.Field); ok && len(field.Names) > 0 {
+			fields[field.Names[0].Name] = field
+		}
+		return true
+	})
+	cases := []struct {
+		field string
+		error string
+	}{
+		{"Count", `api.go:4:2: bad min "1.5" for field Count of type int`},
+		{"Level", `api.go:5:2: bad max "1e3" for field Level of type int64`},
+		{"Limit", `api.go:6:2: bad min "-1" for field Limit of type uint`},
+		{"Login", `api.go:7:2: bad min "-3" for field Login of type string`},
+		{"Names", `api.go:8:2: bad max "2.5" for field Names of type []string`},
+		{"Guild", `api.go:9:2: bad max "x" for field Guild of type *string`},
+		{"Rating", ""},
+	}
+	for _, item := range cases {
+		field := fields[item.field]
+		options := parseStructTag(reflect.StructTag(field.Tag.Value[1 : len(field.Tag.Value)-1]).Get("apivalidator"))
+		_, err := createValidateCode(fset, field, options)
+		if item.error == "" && err != nil || item.error != "" && (err == nil || err.Error() != item.error) {
+			t.Errorf("[%s] expected error %q, got %v", item.field, item.error, err)
+		}
+	}
+}
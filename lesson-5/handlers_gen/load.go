@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// generatedHeader - first line of generated files, such files are skipped when package is loaded
+const generatedHeader = "// Code generated by codegen. DO NOT EDIT.\n\n"
+
+// loadPackage - files of package merged into one file, in order of file names, so output does not depend on
+// order go list returns them. Pattern is package path or directory, for .go file its directory is loaded
+func loadPackage(pattern string) (*token.FileSet, *ast.File, error) {
+	if strings.HasSuffix(pattern, ".go") {
+		pattern = filepath.Dir(pattern)
+	}
+	if info, err := os.Stat(pattern); err == nil && info.IsDir() && !filepath.IsAbs(pattern) && !strings.HasPrefix(pattern, ".") {
+		// directory without ./ would be taken for package path
+		pattern = "./" + pattern
+	}
+
+	fset := token.NewFileSet()
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax,
+		Fset: fset,
+	}
+	pkgs, err := packages.Load(cfg, pattern)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, nil, fmt.Errorf("%s matches %d packages, expected one", pattern, len(pkgs))
+	}
+	pkg := pkgs[0]
+	for _, e := range pkg.Errors {
+		if e.Kind != packages.TypeError {
+			return nil, nil, e
+		}
+	}
+
+	files := append([]*ast.File(nil), pkg.Syntax...)
+	sort.Slice(files, func(i, j int) bool {
+		return fset.File(files[i].Pos()).Name() < fset.File(files[j].Pos()).Name()
+	})
+	merged := &ast.File{Name: ast.NewIdent(pkg.Name)}
+	for _, file := range files {
+		if ast.IsGenerated(file) {
+			continue
+		}
+		merged.Decls = append(merged.Decls, file.Decls...)
+	}
+	return fset, merged, nil
+}
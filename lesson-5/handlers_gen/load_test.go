@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// declsOf - declarations of merged file with files they come from
+func declsOf(t *testing.T, pattern string) []string {
+	fset, node, err := loadPackage(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Name.Name != "multi" {
+		t.Errorf("expected package multi, got %s", node.Name.Name)
+	}
+	var decls []string
+	for _, decl := range node.Decls {
+		var name string
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			name = d.Name.Name
+		case *ast.GenDecl:
+			if spec, ok := d.Specs[0].(*ast.TypeSpec); ok {
+				name = spec.Name.Name
+			} else {
+				name = d.Tok.String()
+			}
+		}
+		decls = append(decls, fmt.Sprintf("%s %s", filepath.Base(fset.Position(decl.Pos()).Filename), name))
+	}
+	return decls
+}
+
+func TestLoadPackage(t *testing.T) {
+	// generated a_gen.go is skipped, other files are merged in order of names
+	expected := []string{
+		"a.go import",
+		"a.go MultiApi",
+		"a.go Create",
+		"b.go import",
+		"b.go CreateParams",
+		"b.go User",
+		"b.go Profile",
+	}
+	for _, pattern := range []string{"testdata/multi", "./testdata/multi", "testdata/multi/b.go"} {
+		for i := 0; i < 3; i++ {
+			if decls := declsOf(t, pattern); !reflect.DeepEqual(decls, expected) {
+				t.Errorf("[%s] bad declarations\nGot: %q\nExpected: %q", pattern, decls, expected)
+			}
+		}
+	}
+
+	// methods and structs of different files are found together
+	fset, node, err := loadPackage("testdata/multi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	methods, err := getApiMethods(fset, node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, method := range methods {
+		names = append(names, method.Func.Name.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"Create", "Profile"}) {
+		t.Errorf("expected methods Create, Profile, got %v", names)
+	}
+	if _, _, _, err := createParamMethod(fset, node, "CreateParams"); err != nil {
+		t.Errorf("expected params of b.go for method of a.go, got %v", err)
+	}
+	if findStruct(node, "GeneratedParams") != nil {
+		t.Error("struct of generated file must be skipped")
+	}
+}
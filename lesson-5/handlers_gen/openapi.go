@@ -0,0 +1,471 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// object - json object of OpenAPI document, encoding/json writes keys sorted, so output is stable
+type object map[string]interface{}
+
+// statusCodes - http.StatusXxx constant names, made from status texts
+var statusCodes = func() map[string]int {
+	codes := map[string]int{}
+	replacer := strings.NewReplacer(" ", "", "-", "", "'", "")
+	for code := 100; code < 600; code++ {
+		if text := http.StatusText(code); text != "" {
+			codes["Status"+replacer.Replace(text)] = code
+		}
+	}
+	return codes
+}()
+
+// findStruct - struct type declared in file
+func findStruct(node *ast.File, name string) *ast.StructType {
+	for _, decl := range node.Decls {
+		g, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		for _, spec := range g.Specs {
+			if ts, ok := spec.(*ast.TypeSpec); ok && ts.Name.Name == name {
+				st, _ := ts.Type.(*ast.StructType)
+				return st
+			}
+		}
+	}
+	return nil
+}
+
+// openAPIBuilder - collects paths and component schemas of one api struct
+type openAPIBuilder struct {
+	fset    *token.FileSet
+	node    *ast.File
+	schemas object
+}
+
+// writeOpenAPI - OpenAPI 3 document for methods of api struct into file.
+// Authenticator is pluggable, so for methods with auth only header it reads is known
+func writeOpenAPI(fset *token.FileSet, node *ast.File, methods []apiMethod, apiName, authHeader, fileName string) error {
+	apiName, err := selectApi(methods, apiName)
+	if err != nil {
+		return err
+	}
+
+	routes, err := buildRoutes(fset, methods)
+	if err != nil {
+		return err
+	}
+	byName := map[string]apiMethod{}
+	for _, method := range methods {
+		if getReceiverType(method.Func) == apiName {
+			byName[method.Func.Name.Name] = method
+		}
+	}
+
+	b := &openAPIBuilder{fset: fset, node: node, schemas: object{}}
+	paths := object{}
+	auth := false
+	for _, r := range routes[apiName] {
+		item := object{}
+		// handler with any method is last, it gets only methods left by others
+		for _, handler := range r.Handlers {
+			method := byName[handler.FuncName]
+			if err := b.addOperations(item, method, r.Allow != ""); err != nil {
+				return err
+			}
+			auth = auth || method.Config.Auth
+		}
+		paths[r.URL] = item
+	}
+
+	components := object{"schemas": b.schemas}
+	if auth {
+		components["securitySchemes"] = object{
+			"ApiKeyAuth": object{"type": "apiKey", "in": "header", "name": authHeader},
+		}
+	}
+	doc := object{
+		"openapi":    "3.0.3",
+		"info":       object{"title": apiName, "version": "1.0.0"},
+		"paths":      paths,
+		"components": components,
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, append(data, '\n'), 0644)
+}
+
+// selectApi - api struct to describe, one document can not hold the same url of two structs
+func selectApi(methods []apiMethod, apiName string) (string, error) {
+	var names []string
+	for _, method := range methods {
+		name := getReceiverType(method.Func)
+		if name == apiName {
+			return name, nil
+		}
+		if len(names) == 0 || names[len(names)-1] != name {
+			names = append(names, name)
+		}
+	}
+	if apiName != "" {
+		return "", fmt.Errorf("api %s not found", apiName)
+	}
+	if len(names) != 1 {
+		return "", fmt.Errorf("several apis %s, choose one with -api", strings.Join(names, ", "))
+	}
+	return names[0], nil
+}
+
+// addOperations - operations of method in path item: GET, HEAD and DELETE read query, others read form or json body.
+// Method without methods in config is described as GET and POST, if url has no other handlers for them
+func (b *openAPIBuilder) addOperations(item object, method apiMethod, restricted bool) error {
+	fd, config := method.Func, method.Config
+	if err := checkPathParams(b.fset, b.node, fd, config.URL); err != nil {
+		return err
+	}
+	properties, required, path, err := b.paramsSchema(findStruct(b.node, getParamType(fd)))
+	if err != nil {
+		return err
+	}
+	responses, err := b.responses(fd, config, restricted)
+	if err != nil {
+		return err
+	}
+
+	operation := func(httpMethod string) object {
+		op := object{
+			"operationId": strings.ToLower(httpMethod) + fd.Name.Name,
+			"responses":   responses,
+		}
+		if config.Auth {
+			op["security"] = []object{{"ApiKeyAuth": []string{}}}
+		}
+		var parameters []object
+		for _, name := range sortedKeys(path) {
+			parameters = append(parameters, object{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   path[name],
+			})
+		}
+		query := httpMethod == http.MethodGet || httpMethod == http.MethodHead || httpMethod == http.MethodDelete
+		if query {
+			for _, name := range sortedKeys(properties) {
+				parameters = append(parameters, object{
+					"name":     name,
+					"in":       "query",
+					"required": contains(required, name),
+					"schema":   properties[name],
+				})
+			}
+		}
+		if len(parameters) > 0 {
+			op["parameters"] = parameters
+		}
+		if query || len(properties) == 0 {
+			return op
+		}
+		body := object{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			body["required"] = required
+		}
+		op["requestBody"] = object{
+			"required": len(required) > 0,
+			"content": object{
+				"application/x-www-form-urlencoded": object{"schema": body},
+				"application/json":                  object{"schema": body},
+			},
+		}
+		return op
+	}
+
+	httpMethods := config.Method
+	if len(httpMethods) == 0 {
+		httpMethods = []string{http.MethodGet, http.MethodPost}
+	}
+	for _, httpMethod := range httpMethods {
+		key := strings.ToLower(httpMethod)
+		if _, ok := item[key]; !ok {
+			item[key] = operation(httpMethod)
+		}
+	}
+	return nil
+}
+
+// paramsSchema - schemas of params struct fields by param name, with apivalidator constraints.
+// Path params are returned separately, they are always required
+func (b *openAPIBuilder) paramsSchema(params *ast.StructType) (object, []string, object, error) {
+	properties, path := object{}, object{}
+	var required []string
+	for _, field := range params.Fields.List {
+		if field.Tag == nil {
+			continue
+		}
+		tag := reflect.StructTag(field.Tag.Value[1 : len(field.Tag.Value)-1])
+		options := parseStructTag(tag.Get("apivalidator"))
+		ft, err := getFieldType(b.fset, field)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		schema := kindSchema(ft.Kind)
+		// value - schema constrained by min, max and enum, for slices it is schema of items
+		value := schema
+		if ft.Slice {
+			schema = object{"type": "array", "items": value}
+		}
+		minKey, maxKey := "minimum", "maximum"
+		switch {
+		case ft.Slice:
+			minKey, maxKey = "minItems", "maxItems"
+		case ft.Kind == "string":
+			minKey, maxKey = "minLength", "maxLength"
+		}
+		if ft.bounded() {
+			for _, bound := range []struct{ option, key string }{{"min", minKey}, {"max", maxKey}} {
+				v, ok := options[bound.option]
+				if !ok {
+					continue
+				}
+				if err := checkBound(b.fset, field, ft, bound.option, v); err != nil {
+					return nil, nil, nil, err
+				}
+				schema[bound.key] = json.Number(v)
+			}
+		}
+		if enum, ok := options["enum"]; ok {
+			value["enum"] = strings.Split(enum, "|")
+		}
+		if v, ok := options["default"]; ok {
+			// the same fields as in generated handlers, so array schema never gets scalar default
+			if err := checkDefault(b.fset, field, ft, v); err != nil {
+				return nil, nil, nil, err
+			}
+			schema["default"] = defaultValue(ft.Kind, v)
+		}
+
+		name := getQueryName(options, field.Names[0].Name)
+		if _, ok := options["path"]; ok {
+			path[name] = schema
+			continue
+		}
+		properties[name] = schema
+		if _, ok := options["required"]; ok {
+			required = append(required, name)
+		}
+	}
+	sort.Strings(required)
+	return properties, required, path, nil
+}
+
+// responses - envelope with result for 200 and {"error"} for every status method can answer with
+func (b *openAPIBuilder) responses(fd *ast.FuncDecl, config *handlerConfig, restricted bool) (object, error) {
+	result, err := b.typeSchema(fd.Type.Results.List[0].Type)
+	if err != nil {
+		return nil, err
+	}
+	responses := object{
+		"200": jsonResponse("success", object{
+			"type": "object",
+			"properties": object{
+				"error":    object{"type": "string"},
+				"response": result,
+			},
+		}),
+	}
+
+	errorSchema := object{"$ref": "#/components/schemas/ApiError"}
+	b.schemas["ApiError"] = object{
+		"type":       "object",
+		"properties": object{"error": object{"type": "string"}},
+	}
+	describe := func(status int, description string) {
+		responses[strconv.Itoa(status)] = jsonResponse(description, errorSchema)
+	}
+	describe(http.StatusBadRequest, "bad params")
+	describe(http.StatusInternalServerError, "unknown error")
+	if config.Auth {
+		describe(http.StatusForbidden, "unauthorized")
+	}
+	if restricted {
+		describe(http.StatusMethodNotAllowed, "bad method")
+	}
+	for _, status := range apiErrorStatuses(fd) {
+		describe(status, http.StatusText(status))
+	}
+	return responses, nil
+}
+
+func jsonResponse(description string, schema object) object {
+	return object{
+		"description": description,
+		"content":     object{"application/json": object{"schema": schema}},
+	}
+}
+
+// apiErrorStatuses - statuses of ApiError literals returned by method
+func apiErrorStatuses(fd *ast.FuncDecl) []int {
+	var statuses []int
+	ast.Inspect(fd.Body, func(n ast.Node) bool {
+		lit, ok := n.(*ast.CompositeLit)
+		if !ok || len(lit.Elts) == 0 {
+			return true
+		}
+		if ident, ok := lit.Type.(*ast.Ident); !ok || ident.Name != "ApiError" {
+			return true
+		}
+		status := lit.Elts[0]
+		for _, elt := range lit.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok && fmt.Sprint(kv.Key) == "HTTPStatus" {
+				status = kv.Value
+			}
+		}
+		switch s := status.(type) {
+		case *ast.SelectorExpr:
+			if code, ok := statusCodes[s.Sel.Name]; ok {
+				statuses = append(statuses, code)
+			}
+		case *ast.BasicLit:
+			if code, err := strconv.Atoi(s.Value); err == nil {
+				statuses = append(statuses, code)
+			}
+		}
+		return true
+	})
+	return statuses
+}
+
+// typeSchema - schema of result type, structs of the file go to components
+func (b *openAPIBuilder) typeSchema(expr ast.Expr) (object, error) {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return b.typeSchema(t.X)
+	case *ast.ArrayType:
+		items, err := b.typeSchema(t.Elt)
+		if err != nil {
+			return nil, err
+		}
+		return object{"type": "array", "items": items}, nil
+	case *ast.MapType:
+		values, err := b.typeSchema(t.Value)
+		if err != nil {
+			return nil, err
+		}
+		return object{"type": "object", "additionalProperties": values}, nil
+	case *ast.InterfaceType:
+		return object{}, nil
+	}
+
+	if kind := kindName(expr); kind != "" {
+		return kindSchema(kind), nil
+	}
+	ident, ok := expr.(*ast.Ident)
+	if !ok {
+		return nil, positionError(b.fset, expr.Pos(), "unsupported result type %s", types.ExprString(expr))
+	}
+	switch ident.Name {
+	case "int8", "int16", "int32", "uint8", "uint16", "uint32", "uint64":
+		return object{"type": "integer"}, nil
+	case "float32":
+		return object{"type": "number"}, nil
+	}
+
+	ref := object{"$ref": "#/components/schemas/" + ident.Name}
+	if _, ok := b.schemas[ident.Name]; ok {
+		return ref, nil
+	}
+	st := findStruct(b.node, ident.Name)
+	if st == nil {
+		return nil, positionError(b.fset, expr.Pos(), "unsupported result type %s", ident.Name)
+	}
+	// placeholder first, so recursive types end up with $ref
+	b.schemas[ident.Name] = object{}
+	properties := object{}
+	for _, field := range st.Fields.List {
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+			jsonName := name.Name
+			if field.Tag != nil {
+				tag := reflect.StructTag(field.Tag.Value[1 : len(field.Tag.Value)-1])
+				jsonName = strings.Split(tag.Get("json"), ",")[0]
+				if jsonName == "-" {
+					continue
+				}
+				if jsonName == "" {
+					jsonName = name.Name
+				}
+			}
+			schema, err := b.typeSchema(field.Type)
+			if err != nil {
+				return nil, err
+			}
+			properties[jsonName] = schema
+		}
+	}
+	b.schemas[ident.Name] = object{"type": "object", "properties": properties}
+	return ref, nil
+}
+
+// kindSchema - schema of supported params type
+func kindSchema(kind string) object {
+	switch kind {
+	case "int":
+		return object{"type": "integer"}
+	case "int64":
+		return object{"type": "integer", "format": "int64"}
+	case "uint":
+		return object{"type": "integer", "minimum": 0}
+	case "float64":
+		return object{"type": "number", "format": "double"}
+	case "bool":
+		return object{"type": "boolean"}
+	case "time.Time":
+		return object{"type": "string", "format": "date-time"}
+	}
+	return object{"type": "string"}
+}
+
+// defaultValue - default from struct tag with json type of field
+func defaultValue(kind, v string) interface{} {
+	switch kind {
+	case "string", "time.Time":
+		return v
+	case "bool":
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return json.Number(v)
+}
+
+func sortedKeys(o object) []string {
+	keys := make([]string, 0, len(o))
+	for key := range o {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
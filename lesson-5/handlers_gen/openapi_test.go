@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// openAPIDoc - document generated for MyApi of lesson api.go, decoded without json.Number
+func openAPIDoc(t *testing.T) map[string]interface{} {
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, "../api.go", nil, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	methods, err := getApiMethods(fset, node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileName := filepath.Join(t.TempDir(), "openapi.json")
+	if err := writeOpenAPI(fset, node, methods, "MyApi", "X-Auth", fileName); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// lookup - value by path of object keys and array indexes, nil if there is no such value
+func lookup(v interface{}, path ...interface{}) interface{} {
+	for _, key := range path {
+		switch k := key.(type) {
+		case string:
+			o, _ := v.(map[string]interface{})
+			v = o[k]
+		case int:
+			a, _ := v.([]interface{})
+			if k >= len(a) {
+				return nil
+			}
+			v = a[k]
+		}
+	}
+	return v
+}
+
+func TestOpenAPIParams(t *testing.T) {
+	doc := openAPIDoc(t)
+	body := lookup(doc, "paths", "/user/create", "post", "requestBody", "content", "application/json", "schema")
+	cases := []struct {
+		path     []interface{}
+		expected interface{}
+	}{
+		{[]interface{}{"properties", "login", "minLength"}, 10.0},
+		{[]interface{}{"properties", "age", "minimum"}, 0.0},
+		{[]interface{}{"properties", "age", "maximum"}, 128.0},
+		{[]interface{}{"properties", "status", "enum"}, []interface{}{"user", "moderator", "admin"}},
+		{[]interface{}{"properties", "status", "default"}, "user"},
+		{[]interface{}{"properties", "full_name", "type"}, "string"},
+		{[]interface{}{"required"}, []interface{}{"login"}},
+	}
+	for _, item := range cases {
+		if got := lookup(body, item.path...); !reflect.DeepEqual(got, item.expected) {
+			t.Errorf("%v: expected %v, got %v", item.path, item.expected, got)
+		}
+	}
+
+	param := lookup(doc, "paths", "/user/by-id/{id}", "get", "parameters", 0)
+	expected := map[string]interface{}{
+		"name":     "id",
+		"in":       "path",
+		"required": true,
+		"schema":   map[string]interface{}{"type": "integer", "minimum": 1.0},
+	}
+	if !reflect.DeepEqual(param, expected) {
+		t.Errorf("expected path param %v, got %v", expected, param)
+	}
+	if params := lookup(doc, "paths", "/user/{login}/profile", "get", "parameters"); len(params.([]interface{})) != 1 ||
+		lookup(params, 0, "name") != "login" || lookup(params, 0, "in") != "path" {
+		t.Errorf("expected only login path param, got %v", params)
+	}
+}
+
+func TestOpenAPIResponses(t *testing.T) {
+	doc := openAPIDoc(t)
+	cases := []struct {
+		path, method string
+		statuses     []string
+	}{
+		// profile takes any method, so it has no 405
+		{"/user/profile", "get", []string{"200", "400", "404", "500"}},
+		{"/user/create", "post", []string{"200", "400", "403", "405", "409", "500"}},
+		{"/user/status", "get", []string{"200", "400", "404", "405", "500"}},
+		{"/user/status", "put", []string{"200", "400", "403", "404", "405", "500"}},
+	}
+	for _, item := range cases {
+		responses, _ := lookup(doc, "paths", item.path, item.method, "responses").(map[string]interface{})
+		statuses := sortedKeys(responses)
+		if !reflect.DeepEqual(statuses, item.statuses) {
+			t.Errorf("%s %s: expected statuses %v, got %v", item.method, item.path, item.statuses, statuses)
+		}
+	}
+	if security := lookup(doc, "paths", "/user/create", "post", "security"); security == nil {
+		t.Error("expected security for method with auth")
+	}
+	if security := lookup(doc, "paths", "/user/profile", "get", "security"); security != nil {
+		t.Errorf("unexpected security for method without auth: %v", security)
+	}
+}
+
+func TestOpenAPISliceDefault(t *testing.T) {
+	src := `package api
+
+type FindParams struct {
+	Names []string ` + "`apivalidator:\"default=warrior\"`" + `
+}
+
+type FindApi struct{}
+
+// apigen:api {"url": "/find", "method": "GET"}
+func (h *FindApi) Find(ctx context.Context, in FindParams) (*FindParams, error) {
+	return nil, nil
+}
+`
+	fset, node := parseSrc(t, src)
+	methods, err := getApiMethods(fset, node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writeOpenAPI(fset, node, methods, "", "X-Auth", filepath.Join(t.TempDir(), "openapi.json"))
+	if err == nil || !strings.Contains(err.Error(), "default is not supported for field Names of type []string") {
+		t.Errorf("expected error for default of slice, got %v", err)
+	}
+}
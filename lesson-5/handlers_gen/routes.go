@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// httpMethods - "method" of config, single method or list of them, empty means any method
+type httpMethods []string
+
+// knownMethods - http package constants for supported methods
+var knownMethods = map[string]string{
+	"GET":     "http.MethodGet",
+	"HEAD":    "http.MethodHead",
+	"POST":    "http.MethodPost",
+	"PUT":     "http.MethodPut",
+	"PATCH":   "http.MethodPatch",
+	"DELETE":  "http.MethodDelete",
+	"OPTIONS": "http.MethodOptions",
+}
+
+func (m *httpMethods) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		var single string
+		if err := json.Unmarshal(data, &single); err != nil {
+			return fmt.Errorf("method must be string or list of strings")
+		}
+		if single != "" {
+			list = []string{single}
+		}
+	}
+	*m = nil
+	for _, method := range list {
+		method = strings.ToUpper(method)
+		if _, ok := knownMethods[method]; !ok {
+			return fmt.Errorf("unsupported method %q", method)
+		}
+		if len(*m) > 0 && m.allows(method) {
+			return fmt.Errorf("method %s is repeated", method)
+		}
+		*m = append(*m, method)
+	}
+	return nil
+}
+
+// allows - request with method is served, any method for empty list
+func (m httpMethods) allows(method string) bool {
+	if len(m) == 0 {
+		return true
+	}
+	for _, v := range m {
+		if v == method {
+			return true
+		}
+	}
+	return false
+}
+
+// routeHandler - method of api struct on url
+type routeHandler struct {
+	Methods  httpMethods
+	FuncName string
+}
+
+// Cases - methods as case list of switch
+func (h routeHandler) Cases() string {
+	cases := make([]string, 0, len(h.Methods))
+	for _, method := range h.Methods {
+		cases = append(cases, knownMethods[method])
+	}
+	return strings.Join(cases, ", ")
+}
+
+// route - handlers of one url, handler with any method goes last
+type route struct {
+	URL      string
+	Handlers []routeHandler
+	// Allow - methods for Allow header, empty if some handler takes any method
+	Allow string
+	// Pattern - url has {name} segments, matched by matchPath instead of switch
+	Pattern bool
+}
+
+// parseURL - names of {name} segments of url
+func parseURL(url string) ([]string, error) {
+	if !strings.HasPrefix(url, "/") {
+		return nil, fmt.Errorf("url %s must start with /", url)
+	}
+	var params []string
+	for _, segment := range strings.Split(url, "/") {
+		if !strings.ContainsAny(segment, "{}") {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(segment, "{"), "}")
+		if "{"+name+"}" != segment || name == "" || strings.ContainsAny(name, "{}") {
+			return nil, fmt.Errorf("bad segment %s of url %s, path param must be whole segment {name}", segment, url)
+		}
+		for _, param := range params {
+			if param == name {
+				return nil, fmt.Errorf("path param %s is repeated in url %s", name, url)
+			}
+		}
+		params = append(params, name)
+	}
+	return params, nil
+}
+
+// urlShape - url with names of path params dropped, urls of the same shape match the same paths
+func urlShape(url string) string {
+	segments := strings.Split(url, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "{") {
+			segments[i] = "{}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// moreSpecific - pattern a is tried before b: in first segment where they differ, literal wins over path param.
+// So /user/me/{id} is matched before /user/{login}/{id}, as it would be in trie.
+// Patterns of different length never match the same path, they are just ordered by length
+func moreSpecific(a, b string) bool {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	if len(as) != len(bs) {
+		return len(as) < len(bs)
+	}
+	for i := range as {
+		aParam, bParam := strings.HasPrefix(as[i], "{"), strings.HasPrefix(bs[i], "{")
+		if aParam != bParam {
+			return bParam
+		}
+	}
+	return false
+}
+
+// pathFields - fields of params struct bound to path params, by param name
+func pathFields(params *ast.StructType) map[string]*ast.Field {
+	fields := map[string]*ast.Field{}
+	for _, field := range params.Fields.List {
+		if field.Tag == nil {
+			continue
+		}
+		tag := reflect.StructTag(field.Tag.Value[1 : len(field.Tag.Value)-1])
+		if name, ok := parseStructTag(tag.Get("apivalidator"))["path"]; ok {
+			fields[name] = field
+		}
+	}
+	return fields
+}
+
+// checkPathParams - every path param of url has field in params struct of method and every path field has param in url
+func checkPathParams(fset *token.FileSet, node *ast.File, fd *ast.FuncDecl, url string) error {
+	names, err := parseURL(url)
+	if err != nil {
+		return positionError(fset, fd.Pos(), "%s", err)
+	}
+	paramsName := getParamType(fd)
+	params := findStruct(node, paramsName)
+	if params == nil {
+		return positionError(fset, fd.Pos(), "params struct %s not found", paramsName)
+	}
+	fields := pathFields(params)
+	for _, name := range names {
+		if _, ok := fields[name]; !ok {
+			return positionError(fset, fd.Pos(), "path param %s of url %s has no field with path=%s in %s",
+				name, url, name, paramsName)
+		}
+		delete(fields, name)
+	}
+	for name, field := range fields {
+		return positionError(fset, field.Pos(), "url %s of %s has no path param %s", url, fd.Name.Name, name)
+	}
+	return nil
+}
+
+// buildRoutes - routes of every api struct: exact urls sorted, then patterns from most specific, sorted among equal.
+// Handlers of one url must not share methods and only one of them may take any method,
+// urls differing only in names of path params are conflicting
+func buildRoutes(fset *token.FileSet, methods []apiMethod) (map[string][]*route, error) {
+	routes := map[string][]*route{}
+	for _, method := range methods {
+		fd, config := method.Func, method.Config
+		typeName := getReceiverType(fd)
+		names, err := parseURL(config.URL)
+		if err != nil {
+			return nil, positionError(fset, fd.Pos(), "%s", err)
+		}
+		var current *route
+		for _, r := range routes[typeName] {
+			if r.URL == config.URL {
+				current = r
+			} else if urlShape(r.URL) == urlShape(config.URL) {
+				return nil, positionError(fset, fd.Pos(), "url %s of %s.%s conflicts with %s of %s",
+					config.URL, typeName, fd.Name.Name, r.URL, r.Handlers[0].FuncName)
+			}
+		}
+		if current == nil {
+			current = &route{URL: config.URL, Pattern: len(names) > 0}
+			routes[typeName] = append(routes[typeName], current)
+		}
+
+		for _, other := range current.Handlers {
+			conflict := len(other.Methods) == 0 && len(config.Method) == 0
+			for _, m := range config.Method {
+				conflict = conflict || len(other.Methods) > 0 && other.Methods.allows(m)
+			}
+			if conflict {
+				return nil, positionError(fset, fd.Pos(), "%s.%s conflicts with %s on %s",
+					typeName, fd.Name.Name, other.FuncName, config.URL)
+			}
+		}
+		handler := routeHandler{Methods: config.Method, FuncName: fd.Name.Name}
+		if n := len(current.Handlers); n > 0 && len(current.Handlers[n-1].Methods) == 0 {
+			current.Handlers = append(current.Handlers[:n-1], handler, current.Handlers[n-1])
+		} else {
+			current.Handlers = append(current.Handlers, handler)
+		}
+	}
+
+	for _, apiRoutes := range routes {
+		for _, r := range apiRoutes {
+			var allow []string
+			for _, handler := range r.Handlers {
+				if len(handler.Methods) == 0 {
+					allow = nil
+					break
+				}
+				allow = append(allow, handler.Methods...)
+			}
+			r.Allow = strings.Join(allow, ", ")
+		}
+	}
+	for typeName, apiRoutes := range routes {
+		var static, patterns []*route
+		for _, r := range apiRoutes {
+			if r.Pattern {
+				patterns = append(patterns, r)
+			} else {
+				static = append(static, r)
+			}
+		}
+		sort.Slice(static, func(i, j int) bool {
+			return static[i].URL < static[j].URL
+		})
+		sort.Slice(patterns, func(i, j int) bool {
+			if moreSpecific(patterns[i].URL, patterns[j].URL) || moreSpecific(patterns[j].URL, patterns[i].URL) {
+				return moreSpecific(patterns[i].URL, patterns[j].URL)
+			}
+			return patterns[i].URL < patterns[j].URL
+		})
+		routes[typeName] = append(static, patterns...)
+	}
+	return routes, nil
+}
+
+// createRouter - cases of ServeHTTP switch by url for routes
+func createRouter(routes []*route) (string, error) {
+	var tmp bytes.Buffer
+	for _, r := range routes {
+		if err := routeTpl.Execute(&tmp, r); err != nil {
+			return "", err
+		}
+	}
+	return tmp.String(), nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// routesSrc - api source with method of struct Api for every config, methods are named M0, M1...
+func routesSrc(configs ...string) string {
+	src := "package api\n\ntype Api struct{}\n"
+	for i, config := range configs {
+		src += fmt.Sprintf("\n// apigen:api %s\nfunc (h *Api) M%d(ctx context.Context, in P) (*R, error) {\n\treturn nil, nil\n}\n", config, i)
+	}
+	return src
+}
+
+func TestBuildRoutesConflicts(t *testing.T) {
+	cases := []struct {
+		configs []string
+		error   string
+	}{
+		{
+			[]string{`{"url": "/u/{login}/p", "method": "GET"}`, `{"url": "/u/{name}/p", "method": "POST"}`},
+			"api.go:11:1: url /u/{name}/p of Api.M1 conflicts with /u/{login}/p of M0",
+		},
+		{
+			[]string{`{"url": "/u", "method": "GET"}`, `{"url": "/u", "method": ["POST", "GET"]}`},
+			"api.go:11:1: Api.M1 conflicts with M0 on /u",
+		},
+		{
+			[]string{`{"url": "/u"}`, `{"url": "/u", "method": "POST"}`, `{"url": "/u"}`},
+			"api.go:16:1: Api.M2 conflicts with M0 on /u",
+		},
+		// the same shape with different literal is not a conflict
+		{[]string{`{"url": "/u/{login}/p"}`, `{"url": "/u/{login}/q"}`, `{"url": "/u/me/p"}`}, ""},
+	}
+	for _, item := range cases {
+		fset, node := parseSrc(t, routesSrc(item.configs...))
+		methods, err := getApiMethods(fset, node)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = buildRoutes(fset, methods)
+		if item.error == "" && err != nil || item.error != "" && (err == nil || err.Error() != item.error) {
+			t.Errorf("%v: expected error %q, got %v", item.configs, item.error, err)
+		}
+	}
+}
+
+func TestBuildRoutesOrder(t *testing.T) {
+	fset, node := parseSrc(t, routesSrc(
+		`{"url": "/u/{login}/{id}", "method": "GET"}`,
+		`{"url": "/u/{login}/p", "method": "GET"}`,
+		`{"url": "/z"}`,
+		`{"url": "/u/me/{id}", "method": "GET"}`,
+		`{"url": "/z", "method": "POST"}`,
+		`{"url": "/u/{login}", "method": "GET"}`,
+		`{"url": "/a", "method": ["PUT", "PATCH"]}`,
+		`{"url": "/a", "method": "GET"}`,
+	))
+	methods, err := getApiMethods(fset, node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes, err := buildRoutes(fset, methods)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// exact urls go first, literal segment is tried before path param in the same place
+	expected := []string{
+		"/a: M6 M7, allow PUT, PATCH, GET",
+		"/z: M4 M2, allow any",
+		"/u/{login}: M5, allow GET",
+		"/u/me/{id}: M3, allow GET",
+		"/u/{login}/p: M1, allow GET",
+		"/u/{login}/{id}: M0, allow GET",
+	}
+	var got []string
+	for _, r := range routes["Api"] {
+		var funcs []string
+		for _, handler := range r.Handlers {
+			funcs = append(funcs, handler.FuncName)
+		}
+		allow := r.Allow
+		if allow == "" {
+			allow = "any"
+		}
+		got = append(got, fmt.Sprintf("%s: %s, allow %s", r.URL, strings.Join(funcs, " "), allow))
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("bad routes\nGot: %q\nExpected: %q", got, expected)
+	}
+}
+
+func TestMoreSpecific(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"/u/me/{id}", "/u/{login}/{id}", true},
+		{"/u/{login}/{id}", "/u/me/{id}", false},
+		{"/u/{login}/p", "/u/{login}/{id}", true},
+		{"/u/{login}", "/u/me/{id}", true},
+		{"/u/{login}/p", "/u/{name}/p", false},
+		{"/u/{name}/p", "/u/{login}/p", false},
+	}
+	for _, item := range cases {
+		if got := moreSpecific(item.a, item.b); got != item.expected {
+			t.Errorf("moreSpecific(%s, %s): expected %v, got %v", item.a, item.b, item.expected, got)
+		}
+	}
+}
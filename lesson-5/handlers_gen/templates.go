@@ -5,10 +5,13 @@ import (
 )
 
 const importStr = `import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"mime"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -17,10 +20,27 @@ const importStr = `import (
 `
 
 var (
+	// serveHTTPTpl - exact urls first, then patterns with path params in order of Patterns
 	serveHTTPTpl = template.Must(template.New("serveHTTPBase").Parse(`func (h *{{.StructName}}) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	switch r.URL.Path {
 {{.Router}}
 	default:
+{{- range .Patterns}}
+		if pathValues, ok := matchPath(r.URL.EscapedPath(), "{{.URL}}"); ok {
+			r = r.WithContext(context.WithValue(r.Context(), pathParamsKey{}, pathValues))
+			switch r.Method {
+{{- range .Handlers}}
+			{{if .Methods}}case {{.Cases}}{{else}}default{{end}}:
+				h.wrapper{{.FuncName}}(w, r)
+{{- end}}
+{{- if .Allow}}
+			default:
+				methodNotAllowed(w, "{{.Allow}}")
+{{- end}}
+			}
+			return
+		}
+{{- end}}
 		response := map[string]string{"error": "unknown method"}
 		body, err := json.Marshal(response)
 		if err != nil {
@@ -39,7 +59,6 @@ var (
 
 	wrapperTpl = template.Must(template.New("wrapperTpl").Parse(`
 func (h *{{.ReceiverType}}) wrapper{{.FuncName}}(w http.ResponseWriter, r *http.Request) {
-	{{.MethodCheck}}
 	{{.AuthCheck}}
 	var respBody []byte
 	params := {{.ParamsType}}{}
@@ -98,8 +117,18 @@ func (h *{{.ReceiverType}}) wrapper{{.FuncName}}(w http.ResponseWriter, r *http.
 	}
 }`))
 
+	// routeTpl - handlers of url chosen by method, handler without methods is default
 	routeTpl = template.Must(template.New("routeTpl").Parse(`	case "{{.URL}}":
-		h.wrapper{{.FuncName}}(w, r)
+		switch r.Method {
+{{- range .Handlers}}
+		{{if .Methods}}case {{.Cases}}{{else}}default{{end}}:
+			h.wrapper{{.FuncName}}(w, r)
+{{- end}}
+{{- if .Allow}}
+		default:
+			methodNotAllowed(w, "{{.Allow}}")
+{{- end}}
+		}
 `))
 
 	intTpl = template.Must(template.New("intTpl").Parse(`
@@ -132,29 +161,48 @@ func (h *{{.ReceiverType}}) wrapper{{.FuncName}}(w http.ResponseWriter, r *http.
 	}
 	in.{{.FieldName}} = val{{.FieldName}}
 `))
-	methodCheckTemplate = `if r.Method != http.MethodPost {
-        w.WriteHeader(http.StatusNotAcceptable)
-		resp := map[string]string{"error": "bad method"}
-		respBody, err := json.Marshal(resp)
+	// scalarTpl - single value, for pointer fields absent param leaves nil
+	scalarTpl = template.Must(template.New("scalarTpl").Parse(`
+	// {{.FieldName}} unpack
+	if keys{{.FieldName}} := {{.Source}}["{{.QueryName}}"]; len(keys{{.FieldName}}) > 0 && keys{{.FieldName}}[0] != "" {
+		raw{{.FieldName}} := keys{{.FieldName}}[0]
+		{{.Parse}}
+		{{- if ne .Desc "string"}}
 		if err != nil {
-			log.Fatal(err)
+			return fmt.Errorf("{{.QueryName}} must be {{.Desc}}")
 		}
-		w.Header().Set("Content-Type", "application/json")
-		_, err = w.Write(respBody)
+		{{- end}}
+		in.{{.FieldName}} = {{if .Pointer}}&{{end}}val{{.FieldName}}
+	}{{if .DefaultVal}} else {
+		in.{{.FieldName}} = {{.DefaultVal}}
+	}{{end}}
+`))
+	// sliceTpl - every repeated param becomes slice item
+	sliceTpl = template.Must(template.New("sliceTpl").Parse(`
+	// {{.FieldName}} unpack
+	for _, raw{{.FieldName}} := range {{.Source}}["{{.QueryName}}"] {
+		{{.Parse}}
+		{{- if ne .Desc "string"}}
 		if err != nil {
-			log.Fatal(err)
+			return fmt.Errorf("{{.QueryName}} must be {{.Desc}}")
 		}
-		return
-    }`
-	authCheckTemplate = `authValue := r.Header.Get("X-Auth")
-	if authValue != "100500" {
-		w.WriteHeader(http.StatusForbidden)
-		resp := map[string]string{"error": "unauthorized"}
-		respBody, err := json.Marshal(resp)
+		{{- end}}
+		in.{{.FieldName}} = append(in.{{.FieldName}}, val{{.FieldName}})
+	}
+`))
+	// authCheckTemplate - ApiError of authenticator keeps its status and message, other errors are 403
+	authCheckTemplate = `r, authErr := authenticate(h, r)
+	if authErr != nil {
+		status, message := http.StatusForbidden, "unauthorized"
+		if apiError, ok := authErr.(ApiError); ok {
+			status, message = apiError.HTTPStatus, apiError.Error()
+		}
+		respBody, err := json.Marshal(map[string]string{"error": message})
 		if err != nil {
 			log.Fatal(err)
 		}
 		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
 		_, err = w.Write(respBody)
 		if err != nil {
 			log.Fatal(err)
@@ -164,16 +212,22 @@ func (h *{{.ReceiverType}}) wrapper{{.FuncName}}(w http.ResponseWriter, r *http.
 
 	unpackBaseTemplate = template.Must(template.New("unpackBaseTemplate").Parse(`func (in *{{.TypeName}}) Unpack(r *http.Request) error {
 	var values url.Values
-	if r.Method == http.MethodGet {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodDelete {
 		values = r.URL.Query()
+	} else if isJSONRequest(r) {
+		var err error
+		values, err = jsonValues(r.Body)
+		if err != nil {
+			return err
+		}
 	} else {
 		body, err := ioutil.ReadAll(r.Body)
 		if err != nil {
-			log.Fatal(err)
+			return fmt.Errorf("bad form body")
 		}
 		values, err = url.ParseQuery(string(body))
 		if err != nil {
-			log.Fatal(err)
+			return fmt.Errorf("bad form body")
 		}
 	}`))
 	validateTpl = template.Must(template.New("routeTpl").Parse(`func (in {{.TypeName}}) Validate() error {
@@ -186,15 +240,242 @@ func (h *{{.ReceiverType}}) wrapper{{.FuncName}}(w http.ResponseWriter, r *http.
 	}
 	return false
 }`
+	// jsonHelpersFunc - JSON body is converted to url.Values, so it goes through the same unpack and validation as form params
+	jsonHelpersFunc = `
+func isJSONRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+func jsonValues(body io.Reader) (url.Values, error) {
+	fields := map[string]interface{}{}
+	decoder := json.NewDecoder(body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, fmt.Errorf("bad json body")
+	}
+	values := url.Values{}
+	for key, value := range fields {
+		items, ok := value.([]interface{})
+		if !ok {
+			items = []interface{}{value}
+		}
+		for _, item := range items {
+			switch v := item.(type) {
+			case string:
+				values.Add(key, v)
+			case json.Number:
+				values.Add(key, v.String())
+			case bool:
+				values.Add(key, strconv.FormatBool(v))
+			case nil:
+			default:
+				return nil, fmt.Errorf("%s must be scalar", key)
+			}
+		}
+	}
+	return values, nil
+}`
+	// authHelpersFunc - api struct may check requests itself by implementing Authenticator,
+	// otherwise registered Authenticator is used
+	authHelpersFunc = `
+// Authenticator - checks request to method with "auth": true and returns who made it
+type Authenticator interface {
+	Authenticate(r *http.Request) (interface{}, error)
+}
+
+// AuthenticatorFunc - function as Authenticator
+type AuthenticatorFunc func(r *http.Request) (interface{}, error)
+
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (interface{}, error) {
+	return f(r)
+}
+
+var registeredAuthenticator Authenticator
+
+// RegisterAuthenticator - Authenticator for api structs which do not implement it, not safe to call while serving
+func RegisterAuthenticator(a Authenticator) {
+	registeredAuthenticator = a
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext - result of Authenticate for request of method with "auth": true
+func PrincipalFromContext(ctx context.Context) (interface{}, bool) {
+	principal := ctx.Value(principalKey{})
+	return principal, principal != nil
+}
+
+func authenticate(api interface{}, r *http.Request) (*http.Request, error) {
+	authenticator, ok := api.(Authenticator)
+	if !ok {
+		authenticator = registeredAuthenticator
+	}
+	if authenticator == nil {
+		return nil, ApiError{http.StatusInternalServerError, fmt.Errorf("authenticator is not set")}
+	}
+	principal, err := authenticator.Authenticate(r)
+	if err != nil {
+		return nil, err
+	}
+	return r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)), nil
+}
+`
+	methodHelpersFunc = `
+func methodNotAllowed(w http.ResponseWriter, allow string) {
+	respBody, err := json.Marshal(map[string]string{"error": "bad method"})
+	if err != nil {
+		log.Fatal(err)
+	}
+	w.Header().Set("Allow", allow)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusMethodNotAllowed)
+	_, err = w.Write(respBody)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+`
+	// pathHelpersFunc - values of path params are put into request context by ServeHTTP and read by Unpack
+	pathHelpersFunc = `
+type pathParamsKey struct{}
+
+func matchPath(path, pattern string) (url.Values, bool) {
+	segments, patternSegments := strings.Split(path, "/"), strings.Split(pattern, "/")
+	if len(segments) != len(patternSegments) {
+		return nil, false
+	}
+	values := url.Values{}
+	for i, segment := range patternSegments {
+		if !strings.HasPrefix(segment, "{") {
+			if segment != segments[i] {
+				return nil, false
+			}
+			continue
+		}
+		value, err := url.PathUnescape(segments[i])
+		if err != nil || value == "" {
+			return nil, false
+		}
+		values.Set(segment[1:len(segment)-1], value)
+	}
+	return values, true
+}
+`
+	pathValuesTemplate = "\n\tpathValues, _ := r.Context().Value(pathParamsKey{}).(url.Values)\n"
+	// clientTpl - client struct of api, do sends request of method with auth if needed
+	clientTpl = template.Must(template.New("clientTpl").Parse(`
+// {{.Name}}Client - http client of {{.Name}}, errors of methods are ApiError with http status of response.
+// Params with zero value are not sent, like server does not unpack them: missing param is zero or its default
+type {{.Name}}Client struct {
+	// URL - address of server, e.g. http://127.0.0.1:8080
+	URL string
+	// AuthToken - value of {{.AuthHeader}} header for methods with auth
+	AuthToken string
+	// HTTPClient - if nil, http.DefaultClient is used
+	HTTPClient *http.Client
+}
+
+func (c *{{.Name}}Client) do(ctx context.Context, method, path string, values url.Values, auth bool, result interface{}) error {
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	header := http.Header{}
+	if auth {
+		header.Set("{{.AuthHeader}}", c.AuthToken)
+	}
+	return doApiRequest(ctx, httpClient, method, strings.TrimSuffix(c.URL, "/")+path, values, header, result)
+}
+`))
+	clientMethodTpl = template.Must(template.New("clientMethodTpl").Parse(`
+// {{.FuncName}} - {{.Method}} {{.URL}}
+{{- if .Defaults}}
+// Zero values are not sent and server uses defaults instead, so these params can not be set to zero: {{.Defaults}}
+{{- end}}
+func (c *{{.ReceiverType}}Client) {{.FuncName}}(ctx context.Context, in {{.ParamsType}}) ({{.Result}}, error) {
+	values := url.Values{}
+{{.Encode}}
+	{{if .Elem}}out := new({{.Elem}}){{else}}var out {{.Result}}{{end}}
+	err := c.do(ctx, {{.MethodConst}}, {{.Path}}, values, {{.Auth}}, {{if not .Elem}}&{{end}}out)
+	if err != nil {
+		return {{if .Elem}}nil{{else}}out{{end}}, err
+	}
+	return out, nil
+}
+`))
+	// clientApiErrorType - ApiError of client in its own package, the same as ApiError of api
+	clientApiErrorType = `
+// ApiError - error of api method with http status of response
+type ApiError struct {
+	HTTPStatus int
+	Err        error
+}
+
+func (ae ApiError) Error() string {
+	return ae.Err.Error()
+}
+`
+	// clientHelpersFunc - GET, HEAD and DELETE send params in query, other methods in form body as server unpacks them
+	clientHelpersFunc = `
+func doApiRequest(ctx context.Context, client *http.Client, method, rawURL string, values url.Values, header http.Header, result interface{}) error {
+	var body io.Reader
+	if method == http.MethodGet || method == http.MethodHead || method == http.MethodDelete {
+		if len(values) > 0 {
+			rawURL += "?" + values.Encode()
+		}
+	} else {
+		body = strings.NewReader(values.Encode())
+		header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
+	if err != nil {
+		return err
+	}
+	for key, value := range header {
+		req.Header[key] = value
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	envelope := struct {
+		Error    string          ` + "`json:\"error\"`" + `
+		Response json.RawMessage ` + "`json:\"response\"`" + `
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return ApiError{resp.StatusCode, fmt.Errorf("bad response: %s", err)}
+	}
+	if resp.StatusCode != http.StatusOK || envelope.Error != "" {
+		return ApiError{resp.StatusCode, errors.New(envelope.Error)}
+	}
+	return json.Unmarshal(envelope.Response, result)
+}
+`
 	requiredTemplateInt = "\tif in.%s == 0 { return fmt.Errorf(\"%s must me not empty\")}\n"
 	requiredTemplateString = "\tif in.%s == \"\" { return fmt.Errorf(\"%s must me not empty\")}\n"
 	minTemplateInt = "\tif in.%s < %s { return fmt.Errorf(\"%s must be >= %s\")}\n"
 	minTemplateString= "\tif len(in.%s) < %s { return fmt.Errorf(\"%s len must be >= %s\")}\n"
 	maxTemplate = "\tif in.%s > %s { return fmt.Errorf(\"%s must be <= %s\")}\n"
+	requiredTemplatePtr = "\tif in.%s == nil { return fmt.Errorf(\"%s must me not empty\")}\n"
+	requiredTemplateSlice = "\tif len(in.%s) == 0 { return fmt.Errorf(\"%s must me not empty\")}\n"
+	requiredTemplateTime = "\tif in.%s.IsZero() { return fmt.Errorf(\"%s must me not empty\")}\n"
+	boundTemplate = "\tif %s %s %s { return fmt.Errorf(\"%s must be %s %s\")}\n"
+	checkEnumGuardTemplate = `	if enumValues := %v; %s!checkEnum(enumValues, %s) {
+		return fmt.Errorf("%s must be one of [%%s]", strings.Join(enumValues, ", "))
+	}
+`
+	checkEnumSliceTemplate = `	for _, v := range in.%[2]s {
+		if enumValues := %[1]v; !checkEnum(enumValues, v) {
+			return fmt.Errorf("%[3]s must be one of [%%s]", strings.Join(enumValues, ", "))
+		}
+	}
+`
 	checkEnumTemplate = `	enumValues := %v
 	if !checkEnum(enumValues, in.%s) {
 		errorMsg := "%s must be one of " + "[" + strings.Join(enumValues, ", ") + "]"
-		return fmt.Errorf(errorMsg)
+		return fmt.Errorf("%%s", errorMsg)
 	}
 `
 )
\ No newline at end of file
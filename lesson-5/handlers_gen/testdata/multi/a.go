@@ -0,0 +1,10 @@
+package multi
+
+import "context"
+
+type MultiApi struct{}
+
+// apigen:api {"url": "/user/create", "method": "POST"}
+func (h *MultiApi) Create(ctx context.Context, in CreateParams) (*User, error) {
+	return &User{Login: in.Login}, nil
+}
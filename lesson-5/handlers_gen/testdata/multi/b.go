@@ -0,0 +1,16 @@
+package multi
+
+import "context"
+
+type CreateParams struct {
+	Login string `apivalidator:"required"`
+}
+
+type User struct {
+	Login string `json:"login"`
+}
+
+// apigen:api {"url": "/user/profile", "method": "GET"}
+func (h *MultiApi) Profile(ctx context.Context, in CreateParams) (*User, error) {
+	return &User{Login: in.Login}, nil
+}
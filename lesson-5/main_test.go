@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -10,6 +11,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/dgkrivenko/cursera-go-pt1/lesson-5/apiclient"
 )
 
 func CheckoutDummy(w http.ResponseWriter, r *http.Request) {
@@ -27,11 +30,16 @@ type Case struct {
 	Auth   bool
 	Status int
 	Result interface{}
+	// ContentType - тело POST, PUT и PATCH запроса, по-умолчанию application/x-www-form-urlencoded
+	ContentType string
+	// Allow - ожидаемый заголовок Allow, проверяется если задан
+	Allow string
 }
 
 const (
 	ApiUserCreate  = "/user/create"
 	ApiUserProfile = "/user/profile"
+	ApiUserStatus  = "/user/status"
 )
 
 // CaseResponse
@@ -136,8 +144,9 @@ func TestMyApi(t *testing.T) {
 			Path:   ApiUserCreate,
 			Method: http.MethodGet,
 			Query:  "login=mr.moderator&age=32&status=moderator&full_name=GetMethod",
-			Status: http.StatusNotAcceptable,
+			Status: http.StatusMethodNotAllowed,
 			Auth:   true,
+			Allow:  "POST",
 			Result: CR{
 				"error": "bad method",
 			},
@@ -245,6 +254,80 @@ func TestMyApi(t *testing.T) {
 				"error": "bad user",
 			},
 		},
+		// ------
+		Case{ // GET и PUT на одном url попадают в разные методы
+			Path:   ApiUserStatus,
+			Query:  "login=mr.moderator",
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"login":  "mr.moderator",
+					"status": "moderator",
+				},
+			},
+		},
+		Case{
+			Path:   ApiUserStatus,
+			Method: http.MethodPut,
+			Query:  "login=mr.moderator&status=admin",
+			Status: http.StatusOK,
+			Auth:   true,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"login":  "mr.moderator",
+					"status": "admin",
+				},
+			},
+		},
+		Case{ // статус действительно изменился
+			Path:   ApiUserStatus,
+			Query:  "login=mr.moderator",
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"login":  "mr.moderator",
+					"status": "admin",
+				},
+			},
+		},
+		Case{ // у метода может быть несколько http методов
+			Path:        ApiUserStatus,
+			Method:      http.MethodPatch,
+			Query:       `{"login": "mr.moderator", "status": "user"}`,
+			ContentType: "application/json",
+			Status:      http.StatusOK,
+			Auth:        true,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"login":  "mr.moderator",
+					"status": "user",
+				},
+			},
+		},
+		Case{ // PUT требует авторизации, GET - нет
+			Path:   ApiUserStatus,
+			Method: http.MethodPut,
+			Query:  "login=mr.moderator&status=admin",
+			Status: http.StatusForbidden,
+			Result: CR{
+				"error": "unauthorized",
+			},
+		},
+		Case{ // DELETE никто не обрабатывает
+			Path:   ApiUserStatus,
+			Method: http.MethodDelete,
+			Query:  "login=mr.moderator",
+			Status: http.StatusMethodNotAllowed,
+			Auth:   true,
+			Allow:  "GET, PUT, PATCH",
+			Result: CR{
+				"error": "bad method",
+			},
+		},
 	}
 
 	runTests(t, ts, cases)
@@ -285,6 +368,457 @@ func TestOtherApi(t *testing.T) {
 	runTests(t, ts, cases)
 }
 
+func TestOtherApiFind(t *testing.T) {
+	ts := httptest.NewServer(NewOtherApi())
+	ApiUserFind := "/user/find"
+
+	cases := []Case{
+		Case{ // значения по-умолчанию, необязательные поля - null
+			Path:   ApiUserFind,
+			Query:  "since=2020-01-02T03:04:05Z",
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"names":  nil,
+					"online": nil,
+					"guild":  nil,
+					"level":  1,
+					"limit":  10,
+					"rating": 0,
+					"since":  "2020-01-02T03:04:05Z",
+				},
+			},
+		},
+		Case{ // повторяющиеся параметры собираются в слайс
+			Path:   ApiUserFind,
+			Query:  "name=warrior&name=rouge&online=false&guild=red&level=7&limit=20&rating=4.5&since=2020-01-02T03:04:05%2B03:00",
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"names":  []string{"warrior", "rouge"},
+					"online": false,
+					"guild":  "red",
+					"level":  7,
+					"limit":  20,
+					"rating": 4.5,
+					"since":  "2020-01-02T03:04:05+03:00",
+				},
+			},
+		},
+		Case{
+			Path:        ApiUserFind,
+			Method:      http.MethodPost,
+			ContentType: "application/json",
+			Query:       `{"name": ["sorcerer"], "online": true, "rating": 5, "since": "2020-01-02T03:04:05Z"}`,
+			Status:      http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"names":  []string{"sorcerer"},
+					"online": true,
+					"guild":  nil,
+					"level":  1,
+					"limit":  10,
+					"rating": 5,
+					"since":  "2020-01-02T03:04:05Z",
+				},
+			},
+		},
+		Case{
+			Path:   ApiUserFind,
+			Query:  "level=7",
+			Status: http.StatusBadRequest,
+			Result: CR{"error": "since must me not empty"},
+		},
+		Case{
+			Path:   ApiUserFind,
+			Query:  "since=yesterday",
+			Status: http.StatusBadRequest,
+			Result: CR{"error": "since must be RFC3339 time"},
+		},
+		Case{
+			Path:   ApiUserFind,
+			Query:  "online=maybe&since=2020-01-02T03:04:05Z",
+			Status: http.StatusBadRequest,
+			Result: CR{"error": "online must be bool"},
+		},
+		Case{
+			Path:   ApiUserFind,
+			Query:  "limit=-1&since=2020-01-02T03:04:05Z",
+			Status: http.StatusBadRequest,
+			Result: CR{"error": "limit must be uint"},
+		},
+		Case{
+			Path:   ApiUserFind,
+			Query:  "rating=5.5&since=2020-01-02T03:04:05Z",
+			Status: http.StatusBadRequest,
+			Result: CR{"error": "rating must be <= 5"},
+		},
+		Case{
+			Path:   ApiUserFind,
+			Query:  "name=warrior&name=paladin&since=2020-01-02T03:04:05Z",
+			Status: http.StatusBadRequest,
+			Result: CR{"error": "name must be one of [warrior, sorcerer, rouge]"},
+		},
+		Case{
+			Path:   ApiUserFind,
+			Query:  "name=rouge&name=rouge&name=rouge&name=rouge&since=2020-01-02T03:04:05Z",
+			Status: http.StatusBadRequest,
+			Result: CR{"error": "name count must be <= 3"},
+		},
+		Case{
+			Path:   ApiUserFind,
+			Query:  "guild=ab&since=2020-01-02T03:04:05Z",
+			Status: http.StatusBadRequest,
+			Result: CR{"error": "guild len must be >= 3"},
+		},
+	}
+
+	runTests(t, ts, cases)
+}
+
+func TestMyApiJSON(t *testing.T) {
+	ts := httptest.NewServer(NewMyApi())
+
+	cases := []Case{
+		Case{ // создаём юзера из json, числа приходят числами
+			Path:        ApiUserCreate,
+			Method:      http.MethodPost,
+			ContentType: "application/json; charset=utf-8",
+			Query:       `{"login": "mr.moderator", "age": 32, "status": "moderator", "full_name": "Ivan Ivanov"}`,
+			Status:      http.StatusOK,
+			Auth:        true,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"id": 43,
+				},
+			},
+		},
+		Case{
+			Path:        ApiUserProfile,
+			Method:      http.MethodPost,
+			ContentType: "application/json",
+			Query:       `{"login": "mr.moderator"}`,
+			Status:      http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"id":        43,
+					"login":     "mr.moderator",
+					"full_name": "Ivan Ivanov",
+					"status":    10,
+				},
+			},
+		},
+		Case{ // валидация работает так же, как для формы
+			Path:        ApiUserCreate,
+			Method:      http.MethodPost,
+			ContentType: "application/json",
+			Query:       `{"login": "new_moderator", "age": 256}`,
+			Status:      http.StatusBadRequest,
+			Auth:        true,
+			Result: CR{
+				"error": "age must be <= 128",
+			},
+		},
+		Case{
+			Path:        ApiUserCreate,
+			Method:      http.MethodPost,
+			ContentType: "application/json",
+			Query:       `{"login": "new_moderator", "age": 3.5}`,
+			Status:      http.StatusBadRequest,
+			Auth:        true,
+			Result: CR{
+				"error": "age must be int",
+			},
+		},
+		Case{ // null - как будто поля нет, status по-умолчанию
+			Path:        ApiUserCreate,
+			Method:      http.MethodPost,
+			ContentType: "application/json",
+			Query:       `{"login": "new_moderator", "status": null}`,
+			Status:      http.StatusOK,
+			Auth:        true,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"id": 44,
+				},
+			},
+		},
+		Case{
+			Path:        ApiUserCreate,
+			Method:      http.MethodPost,
+			ContentType: "application/json",
+			Query:       `{"login": {"first": "new"}}`,
+			Status:      http.StatusBadRequest,
+			Auth:        true,
+			Result: CR{
+				"error": "login must be scalar",
+			},
+		},
+		Case{
+			Path:        ApiUserCreate,
+			Method:      http.MethodPost,
+			ContentType: "application/json",
+			Query:       `login=new_moderator`,
+			Status:      http.StatusBadRequest,
+			Auth:        true,
+			Result: CR{
+				"error": "bad json body",
+			},
+		},
+		Case{ // битое тело формы - тоже ошибка запроса
+			Path:   ApiUserCreate,
+			Method: http.MethodPost,
+			Query:  `login=new_moderator&full_name=%zz`,
+			Status: http.StatusBadRequest,
+			Auth:   true,
+			Result: CR{
+				"error": "bad form body",
+			},
+		},
+	}
+
+	runTests(t, ts, cases)
+}
+
+func TestMyApiPathParams(t *testing.T) {
+	ts := httptest.NewServer(NewMyApi())
+
+	cases := []Case{
+		Case{ // параметр из пути приводится к типу поля
+			Path:   "/user/by-id/42",
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"id":        42,
+					"login":     "rvasily",
+					"full_name": "Vasily Romanov",
+					"status":    20,
+				},
+			},
+		},
+		Case{
+			Path:   "/user/by-id/forty-two",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "id must be int",
+			},
+		},
+		Case{ // и проходит валидацию
+			Path:   "/user/by-id/0",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "id must be >= 1",
+			},
+		},
+		Case{
+			Path:   "/user/by-id/43",
+			Status: http.StatusNotFound,
+			Result: CR{
+				"error": "user not exist",
+			},
+		},
+		Case{ // точный url важнее шаблона /user/{login}/profile
+			Path:   "/user/me/profile",
+			Auth:   true,
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"id":        42,
+					"login":     "rvasily",
+					"full_name": "Vasily Romanov",
+					"status":    20,
+				},
+			},
+		},
+		Case{ // значение из пути нельзя подменить query-параметром
+			Path:   "/user/rvasily/profile",
+			Query:  "login=bad_user",
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"id":        42,
+					"login":     "rvasily",
+					"full_name": "Vasily Romanov",
+					"status":    20,
+				},
+			},
+		},
+		Case{
+			Path:   "/user/me/profile",
+			Status: http.StatusForbidden,
+			Result: CR{
+				"error": "unauthorized",
+			},
+		},
+		Case{
+			Path:   "/user/bad_user/profile",
+			Status: http.StatusInternalServerError,
+			Result: CR{
+				"error": "bad user",
+			},
+		},
+		Case{ // у шаблонов тоже проверяется метод
+			Path:   "/user/rvasily/profile",
+			Method: http.MethodPost,
+			Status: http.StatusMethodNotAllowed,
+			Allow:  "GET",
+			Result: CR{
+				"error": "bad method",
+			},
+		},
+		Case{
+			Path:   "/user/rvasily/profile/",
+			Status: http.StatusNotFound,
+			Result: CR{
+				"error": "unknown method",
+			},
+		},
+	}
+
+	runTests(t, ts, cases)
+}
+
+// TestMyApiPrincipal - методы с авторизацией получают того, кого вернул Authenticate
+func TestMyApiPrincipal(t *testing.T) {
+	api := NewMyApi()
+	api.users["v.pupkin"] = &User{ID: 1, Login: "v.pupkin", Status: statusUser}
+	api.tokens["200"] = "v.pupkin"
+	ts := httptest.NewServer(api)
+	defer ts.Close()
+
+	cases := []struct {
+		method, path, body string
+		expected           string
+	}{
+		// создавать пользователей может любой авторизованный
+		{http.MethodPost, ApiUserCreate, "login=mr.moderator&age=32", `{"error":"","response":{"id":43}}`},
+		{http.MethodGet, "/user/me/profile", "", `{"error":"","response":{"id":1,"login":"v.pupkin","full_name":"","status":0}}`},
+	}
+	for _, item := range cases {
+		req, err := http.NewRequest(item.method, ts.URL+item.path, strings.NewReader(item.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Auth", "200")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("[%s %s] expected http status %v, got %v", item.method, item.path, http.StatusOK, resp.StatusCode)
+		}
+		if string(body) != item.expected {
+			t.Errorf("[%s %s] expected body %s, got %s", item.method, item.path, item.expected, body)
+		}
+	}
+}
+
+func TestMyApiClient(t *testing.T) {
+	ts := httptest.NewServer(NewMyApi())
+	defer ts.Close()
+	api := &apiclient.MyApiClient{URL: ts.URL}
+	ctx := context.Background()
+
+	user, err := api.Profile(ctx, apiclient.ProfileParams{Login: "rvasily"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := &apiclient.User{ID: 42, Login: "rvasily", FullName: "Vasily Romanov", Status: statusAdmin}
+	if !reflect.DeepEqual(user, expected) {
+		t.Errorf("results not match\nGot: %#v\nExpected: %#v", user, expected)
+	}
+
+	// ошибки сервера приходят как ApiError со статусом ответа
+	_, err = api.Profile(ctx, apiclient.ProfileParams{Login: "not_exist_user"})
+	if apiError, ok := err.(apiclient.ApiError); !ok || apiError.HTTPStatus != http.StatusNotFound || apiError.Error() != "user not exist" {
+		t.Errorf("expected ApiError 404 user not exist, got %#v", err)
+	}
+	_, err = api.Create(ctx, apiclient.CreateParams{Login: "mr.moderator", Age: 32})
+	if apiError, ok := err.(apiclient.ApiError); !ok || apiError.HTTPStatus != http.StatusForbidden {
+		t.Errorf("expected ApiError 403, got %#v", err)
+	}
+
+	api.AuthToken = "100500"
+	created, err := api.Create(ctx, apiclient.CreateParams{Login: "mr.moderator", Name: "Ivan Ivanov", Status: "moderator", Age: 32})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if created.ID != 43 {
+		t.Errorf("expected id 43, got %d", created.ID)
+	}
+
+	// параметры из пути подставляются в url
+	user, err = api.LoginProfile(ctx, apiclient.LoginProfileParams{Login: "mr.moderator"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if user.FullName != "Ivan Ivanov" {
+		t.Errorf("expected full_name Ivan Ivanov, got %s", user.FullName)
+	}
+	status, err := api.SetStatus(ctx, apiclient.SetStatusParams{Login: "mr.moderator", Status: "admin"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status.Status != "admin" {
+		t.Errorf("expected status admin, got %s", status.Status)
+	}
+}
+
+func TestOtherApiClient(t *testing.T) {
+	ts := httptest.NewServer(NewOtherApi())
+	defer ts.Close()
+	api := &apiclient.OtherApiClient{URL: ts.URL}
+
+	online, guild := false, "Dark Brotherhood"
+	since := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	in := apiclient.OtherFindParams{
+		Names:  []string{"warrior", "rouge"},
+		Online: &online,
+		Guild:  &guild,
+		Level:  7,
+		Rating: 4.5,
+		Since:  since,
+	}
+	result, err := api.Find(context.Background(), in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Limit не передан - сервер подставил значение по-умолчанию
+	expected := &apiclient.OtherFindResult{
+		Names:  in.Names,
+		Online: &online,
+		Guild:  &guild,
+		Level:  7,
+		Limit:  10,
+		Rating: 4.5,
+		Since:  since,
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("results not match\nGot: %#v\nExpected: %#v", result, expected)
+	}
+
+	_, err = api.Find(context.Background(), apiclient.OtherFindParams{Since: since, Names: []string{"bard"}})
+	if apiError, ok := err.(apiclient.ApiError); !ok || apiError.HTTPStatus != http.StatusBadRequest {
+		t.Errorf("expected ApiError 400, got %#v", err)
+	}
+}
+
 func runTests(t *testing.T, ts *httptest.Server, cases []Case) {
 	for idx, item := range cases {
 		var (
@@ -296,10 +830,14 @@ func runTests(t *testing.T, ts *httptest.Server, cases []Case) {
 
 		caseName := fmt.Sprintf("case %d: [%s] %s %s", idx, item.Method, item.Path, item.Query)
 
-		if item.Method == http.MethodPost {
+		if item.Method == http.MethodPost || item.Method == http.MethodPut || item.Method == http.MethodPatch {
 			reqBody := strings.NewReader(item.Query)
 			req, err = http.NewRequest(item.Method, ts.URL+item.Path, reqBody)
-			req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+			contentType := item.ContentType
+			if contentType == "" {
+				contentType = "application/x-www-form-urlencoded"
+			}
+			req.Header.Add("Content-Type", contentType)
 		} else {
 			req, err = http.NewRequest(item.Method, ts.URL+item.Path+"?"+item.Query, nil)
 		}
@@ -322,6 +860,10 @@ func runTests(t *testing.T, ts *httptest.Server, cases []Case) {
 			t.Errorf("[%s] expected http status %v, got %v", caseName, item.Status, resp.StatusCode)
 			continue
 		}
+		if allow := resp.Header.Get("Allow"); item.Allow != "" && allow != item.Allow {
+			t.Errorf("[%s] expected Allow %q, got %q", caseName, item.Allow, allow)
+			continue
+		}
 
 		err = json.Unmarshal(body, &result)
 		if err != nil {